@@ -0,0 +1,490 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type pricePoint struct {
+	Time  time.Time
+	Price float64
+}
+
+var (
+	alertList    []models.Alert
+	alertListMap = make(map[uint64]int)
+
+	alertListMutex = sync.RWMutex{}
+
+	// alertStates holds whether each alert's condition held on the previous update, so alerts
+	// fire when their condition becomes true rather than on every update while it stays true.
+	alertStates = make(map[uint64]bool)
+
+	alertPriceHistory  = make(map[string][]pricePoint)
+	alertVolumeHistory = make(map[string][]float64)
+
+	chanAlertMarkets = make(chan models.Market, 10240)
+)
+
+const (
+	alertPriceHistoryStep = time.Second * 5
+	alertVolumeHistoryLen = 20
+)
+
+func getAlert(alertID uint64) (alert models.Alert) {
+	alertListMutex.RLock()
+	if alertKey := alertListMap[alertID]; alertKey > 0 && len(alertList) > (alertKey-1) {
+		alert = alertList[alertKey-1]
+	}
+	alertListMutex.RUnlock()
+	return
+}
+
+func updateAlert(alert models.Alert) {
+	if alert.ID == 0 {
+		return
+	}
+
+	alertListMutex.Lock()
+	if alertKey := alertListMap[alert.ID]; alertKey > 0 {
+		alertList[alertKey-1] = alert
+	} else {
+		alertList = append(alertList, alert)
+		alertListMap[alert.ID] = len(alertList)
+	}
+	alertListMutex.Unlock()
+}
+
+func removeAlert(alertID uint64) {
+	alertListMutex.Lock()
+	if alertKey := alertListMap[alertID]; alertKey > 0 {
+		alertList = append(alertList[:alertKey-1], alertList[alertKey:]...)
+		alertListMap = make(map[uint64]int)
+		for index, alert := range alertList {
+			alertListMap[alert.ID] = index + 1
+		}
+	}
+	alertListMutex.Unlock()
+}
+
+func LoadAlertsFromDB() {
+	var alerts []models.Alert
+	if err := utils.SqlDB.Where("status = ?", models.AlertActive).Find(&alerts).Error; err != nil {
+		log.Println(err.Error())
+		return
+	}
+
+	for _, alert := range alerts {
+		updateAlert(alert)
+	}
+}
+
+// validateAlert normalises an alert and checks it has what its kind needs to be evaluated.
+func validateAlert(alert *models.Alert) error {
+	alert.Pair = strings.ToUpper(alert.Pair)
+	alert.Kind = strings.ToLower(alert.Kind)
+	alert.Direction = strings.ToLower(alert.Direction)
+	alert.Exchange = strings.ToLower(alert.Exchange)
+	if alert.Exchange == "" {
+		alert.Exchange = "binance"
+	}
+
+	if alert.Pair == "" {
+		return fmt.Errorf("Pair is required")
+	}
+
+	if alert.Direction != "" && alert.Direction != "above" && alert.Direction != "below" {
+		return fmt.Errorf("Direction must be above or below")
+	}
+
+	if alert.Interval != "" {
+		if _, ok := klineIntervalDurations[alert.Interval]; !ok {
+			return fmt.Errorf("Invalid interval: %s", alert.Interval)
+		}
+	}
+
+	switch alert.Kind {
+	case models.AlertPrice, models.AlertRSI:
+		if alert.Direction == "" || alert.Level <= 0 {
+			return fmt.Errorf("%s alerts need a Direction and a Level", alert.Kind)
+		}
+	case models.AlertChange:
+		if alert.Window <= 0 || alert.Level <= 0 {
+			return fmt.Errorf("change alerts need a Window in seconds and a Level in percent")
+		}
+	case models.AlertVolume:
+		if alert.Level <= 1 {
+			return fmt.Errorf("volume alerts need a Level multiple of the average volume above 1")
+		}
+	case models.AlertBollinger:
+	default:
+		return fmt.Errorf("Kind must be one of price, change, rsi, bollinger or volume")
+	}
+
+	if alert.Status == "" {
+		alert.Status = models.AlertActive
+	}
+	return nil
+}
+
+// saveAlert creates or updates an alert in the database and in the evaluated list.
+func saveAlert(alert models.Alert) (models.Alert, error) {
+	if err := validateAlert(&alert); err != nil {
+		return alert, err
+	}
+
+	if alert.ID == 0 {
+		if err := utils.SqlDB.Create(&alert).Error; err != nil {
+			return alert, err
+		}
+	} else {
+		if getAlert(alert.ID).ID == 0 {
+			var existing models.Alert
+			if err := utils.SqlDB.Where("id = ?", alert.ID).First(&existing).Error; err != nil {
+				return alert, fmt.Errorf("Alert %v not found", alert.ID)
+			}
+		}
+
+		alert.Updatedate = time.Now()
+		if err := utils.SqlDB.Save(&alert).Error; err != nil {
+			return alert, err
+		}
+	}
+
+	alertListMutex.Lock()
+	delete(alertStates, alert.ID)
+	alertListMutex.Unlock()
+
+	if alert.Status == models.AlertActive {
+		updateAlert(alert)
+	} else {
+		removeAlert(alert.ID)
+	}
+	return alert, nil
+}
+
+// deleteAlert removes an alert from the database and stops evaluating it.
+func deleteAlert(alertID uint64) error {
+	if err := utils.SqlDB.Where("id = ?", alertID).Delete(&models.Alert{}).Error; err != nil {
+		return err
+	}
+	removeAlert(alertID)
+	return nil
+}
+
+// searchAlerts lists stored alerts, optionally for one pair and status.
+func searchAlerts(pair, status string) (alerts []models.Alert, err error) {
+	var searchText []string
+	var searchParams []interface{}
+
+	if pair != "" {
+		searchText = append(searchText, "pair = ?")
+		searchParams = append(searchParams, strings.ToUpper(pair))
+	}
+
+	if status != "" {
+		searchText = append(searchText, "status = ?")
+		searchParams = append(searchParams, status)
+	}
+
+	err = utils.SqlDB.Where(strings.Join(searchText, " AND "), searchParams...).Order("createdate desc").Find(&alerts).Error
+	return
+}
+
+// alertCondition reports whether the condition of an alert holds for market, and a description of the values.
+// ok is false when the inputs it needs are not available yet.
+func alertCondition(alert models.Alert, market models.Market) (holds bool, description string, ok bool) {
+	pairexchange := fmt.Sprintf("%s-%s", market.Pair, strings.ToLower(market.Exchange))
+
+	compare := func(value float64) bool {
+		if alert.Direction == "below" {
+			return value <= alert.Level
+		}
+		return value >= alert.Level
+	}
+
+	switch alert.Kind {
+	case models.AlertPrice:
+		return compare(market.Price), fmt.Sprintf("Price %v crossed %s %v", market.Price, alert.Direction, alert.Level), market.Price > 0
+
+	case models.AlertChange:
+		history := alertPriceHistory[pairexchange]
+		from := time.Now().Add(-time.Duration(alert.Window) * time.Second)
+		if len(history) == 0 || history[0].Time.After(from) {
+			return false, "", false
+		}
+
+		start := history[0]
+		for _, point := range history {
+			if point.Time.After(from) {
+				break
+			}
+			start = point
+		}
+
+		change := utils.TruncateFloat((market.Price-start.Price)/start.Price*100, 3)
+		switch alert.Direction {
+		case "above":
+			holds = change >= alert.Level
+		case "below":
+			holds = change <= -alert.Level
+		default:
+			holds = change >= alert.Level || change <= -alert.Level
+		}
+		return holds, fmt.Sprintf("Price changed %v%% in %v", change, time.Duration(alert.Window)*time.Second), true
+
+	case models.AlertRSI:
+		rsi := market.RSI
+		if alert.Interval != "" {
+			rsi = getAnalysis(market.Pair, market.Exchange).Intervals[alert.Interval].RSI
+		}
+		return compare(rsi), fmt.Sprintf("RSI %s %v crossed %s %v", alert.Interval, rsi, alert.Direction, alert.Level), rsi > 0
+
+	case models.AlertBollinger:
+		upper, lower := market.UpperBand, market.LowerBand
+		if alert.Interval != "" {
+			bands := getAnalysis(market.Pair, market.Exchange).Intervals[alert.Interval].BollingerBands
+			upper, lower = bands["upper"], bands["lower"]
+		}
+
+		if upper == 0 || lower == 0 {
+			return false, "", false
+		}
+
+		switch alert.Direction {
+		case "above":
+			holds = market.Price > upper
+		case "below":
+			holds = market.Price < lower
+		default:
+			holds = market.Price > upper || market.Price < lower
+		}
+		return holds, fmt.Sprintf("Price %v broke out of the %s bollinger bands %v - %v", market.Price, alert.Interval, lower, upper), true
+
+	case models.AlertVolume:
+		volumes := alertVolumeHistory[pairexchange]
+		if len(volumes) < alertVolumeHistoryLen/2 {
+			return false, "", false
+		}
+
+		var average float64
+		for _, volume := range volumes {
+			average += volume
+		}
+		average /= float64(len(volumes))
+		if average == 0 {
+			return false, "", false
+		}
+
+		multiple := utils.TruncateFloat(market.Volume/average, 2)
+		return multiple >= alert.Level, fmt.Sprintf("Volume %v is %vx the average %v", market.Volume, multiple, utils.TruncateFloat(average, 8)), true
+	}
+	return false, "", false
+}
+
+// recordAlertHistory keeps the recent prices and closed candle volumes that change and volume alerts compare against.
+func recordAlertHistory(market models.Market, maxWindow time.Duration) {
+	pairexchange := fmt.Sprintf("%s-%s", market.Pair, strings.ToLower(market.Exchange))
+
+	history := alertPriceHistory[pairexchange]
+	if market.Price > 0 && (len(history) == 0 || time.Since(history[len(history)-1].Time) >= alertPriceHistoryStep) {
+		history = append(history, pricePoint{Time: time.Now(), Price: market.Price})
+	}
+	for len(history) > 1 && time.Since(history[1].Time) > maxWindow {
+		history = history[1:]
+	}
+	alertPriceHistory[pairexchange] = history
+
+	if market.Closed == 1 && market.Volume > 0 {
+		volumes := append(alertVolumeHistory[pairexchange], market.Volume)
+		if len(volumes) > alertVolumeHistoryLen {
+			volumes = volumes[len(volumes)-alertVolumeHistoryLen:]
+		}
+		alertVolumeHistory[pairexchange] = volumes
+	}
+}
+
+// evaluateAlerts checks the active alerts of a market after it was updated and notifies the ones that fire.
+func evaluateAlerts(market models.Market) {
+	var alerts []models.Alert
+	maxWindow := time.Hour
+	alertListMutex.RLock()
+	for _, alert := range alertList {
+		if window := time.Duration(alert.Window) * time.Second; window > maxWindow {
+			maxWindow = window
+		}
+		if alert.Pair == market.Pair && strings.EqualFold(alert.Exchange, market.Exchange) {
+			alerts = append(alerts, alert)
+		}
+	}
+	alertListMutex.RUnlock()
+
+	recordAlertHistory(market, maxWindow)
+
+	for _, alert := range alerts {
+		if !alert.Expiry.IsZero() && time.Now().After(alert.Expiry) {
+			alert.Status = models.AlertExpired
+			settleAlert(alert)
+			continue
+		}
+
+		holds, description, ok := alertCondition(alert, market)
+		if !ok {
+			continue
+		}
+
+		alertListMutex.Lock()
+		wasHolding, known := alertStates[alert.ID]
+		alertStates[alert.ID] = holds
+		alertListMutex.Unlock()
+
+		//price and rsi alerts fire on a crossing, so they need a previous state to compare with
+		crossing := alert.Kind == models.AlertPrice || alert.Kind == models.AlertRSI
+		if !holds || wasHolding || (crossing && !known) {
+			continue
+		}
+
+		alert.TriggerCount++
+		alert.LastTriggered = time.Now()
+		if alert.Recurring == 0 {
+			alert.Status = models.AlertTriggered
+		}
+
+		message := description
+		if alert.Message != "" {
+			message = alert.Message + " | " + description
+		}
+		wsBroadcastNotification <- notifications{
+			Type: "alert", Title: "*Alert* " + strings.ToUpper(alert.Kind) + " *" + alert.Pair + "*", Message: message,
+			Severity: "warning", Source: "alert", Pair: alert.Pair,
+		}
+
+		settleAlert(alert)
+
+		if alert.Status == models.AlertActive {
+			alertListMutex.Lock()
+			alertStates[alert.ID] = holds
+			alertListMutex.Unlock()
+		}
+	}
+}
+
+// settleAlert saves an alert the evaluation expired or triggered, which a replay only applies in memory.
+func settleAlert(alert models.Alert) {
+	if isReplayMode() {
+		if alert.Status == models.AlertActive {
+			updateAlert(alert)
+		} else {
+			removeAlert(alert.ID)
+		}
+		return
+	}
+
+	if _, err := saveAlert(alert); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// apiAlertsEvaluate evaluates alerts for every market update fed by the market and orderbook streams.
+func apiAlertsEvaluate() {
+	for market := range chanAlertMarkets {
+		evaluateAlerts(market)
+	}
+}
+
+// handleAlertMessage runs an alert action received on the notifications websocket and returns its result.
+func handleAlertMessage(msg notificationMsgType) (response wsResponseType) {
+	response.Action = msg.Action
+
+	var err error
+	switch msg.Action {
+	case "alertlist":
+		response.Result, err = searchAlerts(msg.Pair, msg.Status)
+	case "alertcreate", "alertupdate":
+		if msg.Action == "alertcreate" {
+			msg.Alert.ID = 0
+		}
+		response.Result, err = saveAlert(msg.Alert)
+	case "alertdelete":
+		err = deleteAlert(msg.Alert.ID)
+		response.Result = msg.Alert.ID
+	default:
+		err = fmt.Errorf("Unknown action: %s", msg.Action)
+	}
+
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return
+}
+
+func restHandlerAlerts(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	if utils.SqlDB == nil {
+		http.Error(httpRes, "Database not configured", http.StatusInternalServerError)
+		return
+	}
+
+	var result interface{}
+	switch httpReq.Method {
+	case http.MethodGet:
+		alerts, err := searchAlerts(query.Get("pair"), query.Get("status"))
+		if err != nil {
+			http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = alerts
+
+	case http.MethodPost, http.MethodPut:
+		var alert models.Alert
+		if err := json.NewDecoder(httpReq.Body).Decode(&alert); err != nil {
+			http.Error(httpRes, "Invalid JSON payload", http.StatusBadRequest)
+			return
+		}
+
+		if httpReq.Method == http.MethodPost {
+			alert.ID = 0
+		} else if alert.ID == 0 {
+			http.Error(httpRes, "Missing alert ID", http.StatusBadRequest)
+			return
+		}
+
+		alert, err := saveAlert(alert)
+		if err != nil {
+			http.Error(httpRes, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = alert
+
+	case http.MethodDelete:
+		alertID, err := strconv.ParseUint(query.Get("id"), 10, 64)
+		if err != nil {
+			http.Error(httpRes, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+
+		if err := deleteAlert(alertID); err != nil {
+			http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result = alertID
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(result)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
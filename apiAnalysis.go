@@ -10,8 +10,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 type analysisType struct {
@@ -19,6 +17,7 @@ type analysisType struct {
 	Trend     string
 	Exchange  string
 	Intervals map[string]utils.Summary
+	Zones     []utils.SRZone
 }
 
 var (
@@ -27,10 +26,11 @@ var (
 
 	analysisListMutex    = sync.RWMutex{}
 	analysisListMapMutex = sync.RWMutex{}
-	wsConnAnalysisMutex  = sync.RWMutex{}
 
-	wsConnAnalysis      = make(map[*websocket.Conn]bool)
 	wsBroadcastAnalysis = make(chan analysisType, 10240)
+
+	analysisPublished      = make(map[string]time.Time)
+	analysisPublishedMutex = sync.Mutex{}
 )
 
 func getAnalysis(analysisPair, analysisExchange string) (analysis analysisType) {
@@ -55,63 +55,77 @@ func getAnalysis(analysisPair, analysisExchange string) (analysis analysisType)
 func wsHandlerAnalysis(httpRes http.ResponseWriter, httpReq *http.Request) {
 	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
 
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
+		client := wsHubAnalysis.register(wsConn, httpReq, sendAnalysis)
+		defer client.close()
 
-		analysisListMutex.RLock()
-		for _, analysis := range analysisList {
-			wsConn.WriteJSON(analysis)
-		}
-		analysisListMutex.RUnlock()
+		for {
+			var msgReq struct {
+				wsSubscriptionMsgType
+				Timeframe string
+				Price     float64
+			}
 
-		wsConnAnalysisMutex.Lock()
-		wsConnAnalysis[wsConn] = true
-		wsConnAnalysisMutex.Unlock()
+			if err := wsConn.ReadJSON(&msgReq); err != nil {
+				return
+			}
 
-	}
-}
+			if client.handle(msgReq.wsSubscriptionMsgType) {
+				continue
+			}
 
-func wsHandlerAnalysisBroadcast() {
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			wsConnAnalysisMutex.Lock()
-			for wsConn := range wsConnAnalysis {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnAnalysis, wsConn)
-					wsConn.Close()
+			switch msgReq.Action {
+			case "explain":
+				if msgReq.Pair == "" {
+					continue
 				}
+
+				explain, err := retrieveOpportunityExplain(msgReq.Pair, msgReq.Exchange, msgReq.Timeframe, msgReq.Price)
+				if err != nil {
+					log.Println(err.Error())
+				}
+				client.reply(msgReq.ID, "explain", explain, err)
 			}
-			wsConnAnalysisMutex.Unlock()
 		}
-	}()
+	}
+}
 
-	go func() {
-		ticker := time.NewTicker(time.Second * 3)
-		defer ticker.Stop()
-		for ; true; <-ticker.C {
-			analysisListMutex.RLock()
-			for _, analysis := range analysisList {
-				wsBroadcastAnalysis <- analysis
-			}
-			analysisListMutex.RUnlock()
+// filterAnalysis narrows an analysis to the intervals a connection subscribed to, reporting false
+// when it did not subscribe to the market at all.
+func filterAnalysis(analysis analysisType, subscriptions *wsSubscriptionsType) (analysisType, bool) {
+	intervals, subscribed := subscriptions.intervals(analysis.Pair, analysis.Exchange)
+	if !subscribed || intervals == nil {
+		return analysis, subscribed
+	}
+
+	filtered := analysis
+	filtered.Intervals = make(map[string]utils.Summary)
+	for interval, summary := range analysis.Intervals {
+		if intervals[interval] {
+			filtered.Intervals[interval] = summary
 		}
-	}()
+	}
+	return filtered, true
+}
+
+// sendAnalysis queues for a client the analysis subscriptions match.
+func sendAnalysis(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	analysisListMutex.RLock()
+	defer analysisListMutex.RUnlock()
 
+	for _, analysis := range analysisList {
+		if filtered, ok := filterAnalysis(analysis, subscriptions); ok {
+			client.send("analysis", fmt.Sprintf("%s-%s", analysis.Pair, strings.ToLower(analysis.Exchange)), filtered)
+		}
+	}
+}
+
+func wsHandlerAnalysisBroadcast() {
 	go func() {
 		for analysis := range wsBroadcastAnalysis {
-			wsConnAnalysisMutex.Lock()
-			for wsConn := range wsConnAnalysis {
-				if err := wsConn.WriteJSON(analysis); err != nil {
-					delete(wsConnAnalysis, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnAnalysisMutex.Unlock()
+			wsHubAnalysis.broadcast("analysis", fmt.Sprintf("%s-%s", analysis.Pair, strings.ToLower(analysis.Exchange)), analysis,
+				func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+					return filterAnalysis(analysis, subscriptions)
+				})
 		}
 	}()
 }
@@ -144,6 +158,25 @@ func updateAnalysis(analysis analysisType) {
 		analysisList[pairKey-1] = analysis
 		analysisListMutex.Unlock()
 	}
+
+	// clients get each analysis as it is refreshed, at most every few seconds a market, rather
+	// than the whole list on a timer, and the recorder keeps what they got for a replay
+	publishKey := fmt.Sprintf("%s-%s", analysis.Pair, strings.ToLower(analysis.Exchange))
+	analysisPublishedMutex.Lock()
+	publish := time.Since(analysisPublished[publishKey]) >= time.Second*3
+	if publish {
+		analysisPublished[publishKey] = time.Now()
+	}
+	analysisPublishedMutex.Unlock()
+
+	if publish {
+		recordEvent(recordType{Kind: RecordAnalysis, Analysis: &analysis})
+
+		select {
+		case wsBroadcastAnalysis <- analysis:
+		default:
+		}
+	}
 }
 
 func GoFetchEnabledMarketsAnalysis() {
@@ -211,6 +244,18 @@ func restHandlerAnalysis(httpRes http.ResponseWriter, httpReq *http.Request) {
 	httpRes.Write(jsonResponse)
 }
 
+// analysisKlinesLimit raises the klines of an analysis to the chart pattern window, so swing patterns
+// see the lookback chartpatterns.window asks for, within the 1000 klines of a request.
+func analysisKlinesLimit(limit int) int {
+	if window := utils.Config.ChartPatterns.Window; limit < window {
+		limit = window
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	return limit
+}
+
 func retrieveMarketPairAnalysis(pair, exchange, limit, endTime, startTime, intervals string) (analysis analysisType, err error) {
 	if intervals == "" {
 		// intervals = "1m,3m,5m,15m,30m,1h,4h,6h,12h,1d,3d"
@@ -225,6 +270,7 @@ func retrieveMarketPairAnalysis(pair, exchange, limit, endTime, startTime, inter
 		err = fmt.Errorf("Error preparing request: for pair: %s | exchange: %s -> %v", pair, exchange, errSub)
 		return
 	}
+	request.Limit = analysisKlinesLimit(request.Limit)
 
 	candlesticks := make(map[string][]TypeKline)
 	switch request.Exchange {
@@ -268,5 +314,13 @@ func retrieveMarketPairAnalysis(pair, exchange, limit, endTime, startTime, inter
 	//
 	analysis.Trend = utils.TimeframeTrends(analysis.Intervals)
 
+	//cluster swing zones across all time frames
+	var price float64
+	for _, interval := range analysis.Intervals {
+		price = interval.Candle.Close
+		break
+	}
+	analysis.Zones = utils.MergeTimeframeZones(analysis.Intervals, price, utils.Config.ChartPatterns.ZoneTolerance)
+
 	return
 }
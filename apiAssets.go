@@ -8,9 +8,6 @@ import (
 	"net/http"
 	"strings"
 	"sync"
-	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
@@ -19,9 +16,7 @@ var (
 
 	assetListMutex    = sync.RWMutex{}
 	assetListMapMutex = sync.RWMutex{}
-	wsConnAssetsMutex = sync.RWMutex{}
 
-	wsConnAssets     = make(map[*websocket.Conn]bool)
 	wsBroadcastAsset = make(chan *wsResponseType, 10240)
 )
 
@@ -45,46 +40,44 @@ func getAsset(symbol, assetExchange string) (asset models.Asset) {
 	return
 }
 
-func wsHandlerAssets(httpRes http.ResponseWriter, httpReq *http.Request) {
-	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
-
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
-
-		var filteredAssetList []models.Asset
-		assetListMutex.RLock()
-		for _, asset := range assetList {
-			// if asset.Free > 0 || asset.Locked > 0 {
-			switch {
-			case strings.HasSuffix(asset.Symbol, "UP"):
-			case strings.HasSuffix(asset.Symbol, "DOWN"):
-			case strings.HasSuffix(asset.Symbol, "BULL"):
-			case strings.HasSuffix(asset.Symbol, "BEAR"):
-			default:
-				filteredAssetList = append(filteredAssetList, asset)
-			}
-			// }
+// sendAssets queues for a client the assets held, leaving out the leveraged tokens.
+func sendAssets(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	var filteredAssetList []models.Asset
+	assetListMutex.RLock()
+	for _, asset := range assetList {
+		// if asset.Free > 0 || asset.Locked > 0 {
+		switch {
+		case strings.HasSuffix(asset.Symbol, "UP"):
+		case strings.HasSuffix(asset.Symbol, "DOWN"):
+		case strings.HasSuffix(asset.Symbol, "BULL"):
+		case strings.HasSuffix(asset.Symbol, "BEAR"):
+		default:
+			filteredAssetList = append(filteredAssetList, asset)
 		}
-		assetListMutex.RUnlock()
+		// }
+	}
+	assetListMutex.RUnlock()
 
-		wsConn.WriteJSON(&wsResponseType{Action: "fetchassets", Result: filteredAssetList})
+	client.send("fetchassets", "", filteredAssetList)
+}
 
-		wsConnAssetsMutex.Lock()
-		wsConnAssets[wsConn] = true
-		wsConnAssetsMutex.Unlock()
+func wsHandlerAssets(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
+
+		client := wsHubAssets.register(wsConn, httpReq, sendAssets)
+		defer client.close()
 
 		for {
 			var msgReq struct {
-				Symbol, Exchange string
+				wsSubscriptionMsgType
+				Symbol string
 			}
 
 			if err := wsConn.ReadJSON(&msgReq); err != nil {
 				return
 			}
 
-			if msgReq.Symbol == "" {
+			if client.handle(msgReq.wsSubscriptionMsgType) || msgReq.Symbol == "" {
 				continue
 			}
 
@@ -101,48 +94,21 @@ func wsHandlerAssets(httpRes http.ResponseWriter, httpReq *http.Request) {
 			orderby := "symbol, exchange"
 
 			var foundAssets []models.Asset
-			if err := utils.SqlDB.Where(searchText, searchParams...).Order(orderby).Find(&foundAssets).Error; err != nil {
+			err := utils.SqlDB.Where(searchText, searchParams...).Order(orderby).Find(&foundAssets).Error
+			if err != nil {
 				log.Println(err.Error())
 			}
 
-			wsConnAssetsMutex.Lock()
-			if err := wsConn.WriteJSON(&wsResponseType{Action: "searchresult", Result: foundAssets}); err != nil {
-				log.Println(err.Error())
-				return
-			}
-			wsConnAssetsMutex.Unlock()
+			client.reply(msgReq.ID, "searchresult", foundAssets, err)
 		}
 
 	}
 }
 
 func wsHandlerAssetBroadcast() {
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			wsConnAssetsMutex.Lock()
-			for wsConn := range wsConnAssets {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnAssets, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnAssetsMutex.Unlock()
-		}
-	}()
-
 	go func() {
 		for asset := range wsBroadcastAsset {
-			wsConnAssetsMutex.Lock()
-			for wsConn := range wsConnAssets {
-				if err := wsConn.WriteJSON(asset); err != nil {
-					delete(wsConnAssets, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnAssetsMutex.Unlock()
+			wsHubAssets.broadcast(asset.Action, "", asset.Result, nil)
 		}
 	}()
 }
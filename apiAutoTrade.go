@@ -0,0 +1,167 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	autoTradeMutex   = sync.RWMutex{}
+	autoTradeEnabled bool
+	autoTradeLastMap = make(map[string]time.Time)
+)
+
+// setAutoTradeEnabled flips the global kill switch; when off no opportunity is traded on any market.
+func setAutoTradeEnabled(enabled bool) {
+	autoTradeMutex.Lock()
+	autoTradeEnabled = enabled
+	autoTradeMutex.Unlock()
+
+	state := "disabled"
+	if enabled {
+		state = "enabled"
+	}
+	wsBroadcastNotification <- notifications{Type: "info", Title: "*Auto Trade*", Message: "Auto trading " + state, Severity: "warning", Source: "risk"}
+}
+
+func isAutoTradeEnabled() bool {
+	autoTradeMutex.RLock()
+	defer autoTradeMutex.RUnlock()
+	return autoTradeEnabled
+}
+
+// autoTradeQuantity sizes an order worth quoteAmount at price, rounded down to the market step size
+// and 0 when it falls below the market minimums.
+func autoTradeQuantity(market models.Market, price, quoteAmount float64) float64 {
+	if price == 0 || quoteAmount <= 0 {
+		return 0
+	}
+
+	quantity := quoteAmount / price
+	if market.StepSize > 0 {
+		quantity = math.Floor(quantity/market.StepSize) * market.StepSize
+	}
+	quantity = utils.TruncateFloat(quantity, 8)
+
+	if quantity < market.MinQty || quantity*price < market.MinNotional {
+		return 0
+	}
+	if market.MaxQty > 0 && quantity > market.MaxQty {
+		quantity = market.MaxQty
+	}
+	return quantity
+}
+
+// autoTradeOpportunity places the order for an opportunity on a market with auto trading switched on,
+// unless the kill switch is off or the market is still cooling down from its previous trade.
+func autoTradeOpportunity(opportunity models.Opportunity, price float64) {
+	if !isAutoTradeEnabled() || price == 0 {
+		return
+	}
+
+	market := getMarket(opportunity.Pair, opportunity.Exchange)
+	if market.Status != "enabled" || market.AutoTrade != 1 || !isMarketTradable(market) {
+		return
+	}
+
+	pairexchange := fmt.Sprintf("%s-%s", opportunity.Pair, strings.ToLower(opportunity.Exchange))
+	autoTradeMutex.Lock()
+	if lastTrade, ok := autoTradeLastMap[pairexchange]; ok && time.Since(lastTrade) < utils.Config.AutoTrade.Cooldown {
+		autoTradeMutex.Unlock()
+		return
+	}
+	autoTradeLastMap[pairexchange] = time.Now()
+	autoTradeMutex.Unlock()
+
+	quoteAmount := market.AutoTradeQuote
+	if quoteAmount == 0 {
+		quoteAmount = utils.Config.AutoTrade.QuoteAmount
+	}
+
+	quantity := autoTradeQuantity(market, price, quoteAmount)
+	if quantity == 0 {
+		log.Printf("Auto trade skipped for %s: %v quote is below the market minimums \n", pairexchange, quoteAmount)
+		return
+	}
+
+	if err := checkSlippage(opportunity.Pair, opportunity.Exchange, opportunity.Action, quantity); err != nil {
+		wsBroadcastNotification <- notifications{
+			Type: "info", Title: "*Auto Trade* skipped *" + opportunity.Pair + "*", Message: err.Error(),
+			Severity: "warning", Source: "risk", Pair: opportunity.Pair, OpportunityID: opportunity.ID,
+		}
+		return
+	}
+
+	stoploss, takeprofit := opportunityOrderPercentages(opportunity, price)
+	autorepeat := utils.Config.AutoTrade.AutoRepeat
+
+	if _, err := placeOpportunityOrder(opportunity, quantity, price, stoploss, takeprofit, autorepeat); err != nil {
+		log.Printf("Auto trade %s: %v \n", pairexchange, err)
+		return
+	}
+
+	wsBroadcastNotification <- notifications{
+		Type: "info", Title: "*Auto Trade* " + opportunity.Action + " *" + opportunity.Pair + "*",
+		Message: fmt.Sprintf("Price: %v | Qty: %v | TP: %v%% | SL: %v%%", price, quantity, takeprofit, stoploss),
+		Source:  "strategy", Pair: opportunity.Pair, OpportunityID: opportunity.ID,
+	}
+}
+
+// setMarketAutoTrade switches auto trading on or off for a single market.
+func setMarketAutoTrade(pair, exchange string, autoTrade int) {
+	market := getMarket(pair, exchange)
+	if market.Pair == "" {
+		return
+	}
+
+	market.AutoTrade = autoTrade
+	updateMarket(market)
+	wsBroadcastMarket <- market
+	if err := utils.SqlDB.Model(&market).Where("pair = ? and exchange = ?", market.Pair, market.Exchange).Updates(
+		map[string]interface{}{"autotrade": autoTrade}).Error; err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// restHandlerAutoTrade reports the auto trading settings, the kill switch being flipped only by a POST
+// so that a followed link or a prefetch can never switch live trading on.
+func restHandlerAutoTrade(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	if enabled := query.Get("enabled"); enabled != "" {
+		if httpReq.Method != "POST" {
+			http.Error(httpRes, "The enabled parameter needs a POST", http.StatusMethodNotAllowed)
+			return
+		}
+
+		isEnabled, err := strconv.ParseBool(enabled)
+		if err != nil {
+			http.Error(httpRes, "Invalid enabled parameter", http.StatusBadRequest)
+			return
+		}
+		setAutoTradeEnabled(isEnabled)
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(map[string]interface{}{
+		"Enabled":     isAutoTradeEnabled(),
+		"Cooldown":    utils.Config.AutoTrade.Cooldown.String(),
+		"QuoteAmount": utils.Config.AutoTrade.QuoteAmount,
+		"AutoRepeat":  utils.Config.AutoTrade.AutoRepeat,
+	})
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
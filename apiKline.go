@@ -1,6 +1,7 @@
 package main
 
 import (
+	"backpocket/utils"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -125,3 +126,103 @@ func prepareKlineRequest(pair, exchange, intervals, limit, starttime, endtime st
 
 	return
 }
+
+// restHandlerVolumeProfile returns the volume profile of the klines of each interval, or with
+// source=trades of the one second trade buckets of the trades window, keyed by "trades".
+func restHandlerVolumeProfile(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	pair := query.Get("pair")
+	exchange := query.Get("exchange")
+	intervals := query.Get("intervals")
+
+	if exchange == "" {
+		exchange = "binance"
+	}
+
+	if intervals == "" {
+		intervals = "15m"
+	}
+
+	if pair == "" {
+		http.Error(httpRes, "Missing parameters pair, intervals", http.StatusBadRequest)
+		return
+	}
+
+	bins := utils.Config.VolumeProfile.Bins
+	if query.Get("bins") != "" {
+		var err error
+		if bins, err = strconv.Atoi(query.Get("bins")); err != nil || bins < 2 {
+			http.Error(httpRes, "Invalid bins parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	valueArea := utils.Config.VolumeProfile.ValueArea
+	if query.Get("valuearea") != "" {
+		var err error
+		if valueArea, err = strconv.ParseFloat(query.Get("valuearea"), 64); err != nil || valueArea <= 0 || valueArea > 1 {
+			http.Error(httpRes, "Invalid valuearea parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	profiles := make(map[string]utils.VolumeProfile)
+	if query.Get("source") == "trades" {
+		var marketData utils.MarketData
+		for _, bucket := range getTradeFlow(strings.ToUpper(pair), exchange).Buckets {
+			marketData.Open = append(marketData.Open, bucket.Open)
+			marketData.High = append(marketData.High, bucket.High)
+			marketData.Low = append(marketData.Low, bucket.Low)
+			marketData.Close = append(marketData.Close, bucket.Close)
+			marketData.Volume = append(marketData.Volume, bucket.BuyVolume+bucket.SellVolume)
+		}
+
+		if len(marketData.Close) > 0 {
+			profiles["trades"] = utils.CalculateVolumeProfile(marketData, bins, valueArea)
+		}
+	} else {
+		request, err := prepareKlineRequest(pair, exchange, intervals, query.Get("limit"), query.Get("starttime"), query.Get("endtime"))
+		if err != nil {
+			http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		candlesticks := make(map[string][]TypeKline)
+		switch request.Exchange {
+		case "binance":
+			candlesticks = binanceKlines(request.Intervals, request.Pair,
+				request.StartTime, request.EndTime, request.Limit)
+		}
+
+		for interval, klines := range candlesticks {
+			var marketData utils.MarketData
+			for _, kline := range klines {
+				marketData.Open = append(marketData.Open, kline.Open)
+				marketData.High = append(marketData.High, kline.High)
+				marketData.Low = append(marketData.Low, kline.Low)
+				marketData.Close = append(marketData.Close, kline.Close)
+				marketData.Volume = append(marketData.Volume, kline.Volume)
+			}
+
+			if len(marketData.Close) > 0 {
+				profiles[interval] = utils.CalculateVolumeProfile(marketData, bins, valueArea)
+			}
+		}
+	}
+
+	if len(profiles) == 0 {
+		err := fmt.Errorf("No data found for pair: %s | exchange: %s", pair, exchange)
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(profiles)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
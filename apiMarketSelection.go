@@ -0,0 +1,176 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type marketRankType struct {
+	Market     models.Market
+	Spread     float64
+	Volatility float64
+	Rank       int
+	Eligible   bool
+}
+
+// marketSpread prefers the live orderbook spread of a streamed market over the 24h ticker one.
+func marketSpread(market models.Market) float64 {
+	orderbook := getOrderbook(market.Pair, market.Exchange)
+	orderbookMutex.RLock()
+	defer orderbookMutex.RUnlock()
+	if len(orderbook.Bids) > 0 && len(orderbook.Asks) > 0 && orderbook.Bids[0].Price > 0 {
+		return utils.TruncateFloat((orderbook.Asks[0].Price-orderbook.Bids[0].Price)/orderbook.Bids[0].Price*100, 4)
+	}
+	return market.Spread
+}
+
+// marketHasActiveOrders reports whether a market still has open orders or orders with a
+// stoploss/takeprofit being watched, which must keep it enabled.
+func marketHasActiveOrders(pair, exchange string) bool {
+	orderListMutex.RLock()
+	defer orderListMutex.RUnlock()
+	for _, order := range orderList {
+		if order.Pair != pair || !strings.EqualFold(order.Exchange, exchange) {
+			continue
+		}
+
+		if order.RefEnabled == 1 || order.Status == "NEW" || order.Status == "PARTIALLY_FILLED" {
+			return true
+		}
+	}
+	return false
+}
+
+// rankMarkets scores the markets of each quote asset by 24h quote volume, spread and volatility, where
+// rank 1 is the best sum of the three individual rankings, and flags which clear the configured
+// thresholds. VolumeQuote24h is used as the streams of an enabled market keep only the current
+// candle's volume in VolumeQuote.
+func rankMarkets(markets []models.Market) map[string][]marketRankType {
+	policy := utils.Config.MarketSelection
+
+	quoteRanks := make(map[string][]marketRankType)
+	for _, market := range markets {
+		ranked := marketRankType{Market: market, Spread: marketSpread(market)}
+		if market.LowPrice > 0 {
+			ranked.Volatility = utils.TruncateFloat((market.HighPrice-market.LowPrice)/market.LowPrice*100, 3)
+		}
+
+		ranked.Eligible = isMarketTradable(market) && market.VolumeQuote24h >= policy.MinVolumeQuote &&
+			ranked.Spread > 0 && ranked.Spread <= policy.MaxSpread &&
+			ranked.Volatility >= policy.MinVolatility &&
+			(policy.MaxVolatility == 0 || ranked.Volatility <= policy.MaxVolatility)
+		quoteRanks[market.QuoteAsset] = append(quoteRanks[market.QuoteAsset], ranked)
+	}
+
+	for quoteAsset, ranks := range quoteRanks {
+		points := make([]int, len(ranks))
+		for _, less := range []func(a, b marketRankType) bool{
+			func(a, b marketRankType) bool { return a.Market.VolumeQuote24h > b.Market.VolumeQuote24h },
+			func(a, b marketRankType) bool { return a.Spread < b.Spread },
+			func(a, b marketRankType) bool { return a.Volatility > b.Volatility },
+		} {
+			order := make([]int, len(ranks))
+			for index := range order {
+				order[index] = index
+			}
+			sort.SliceStable(order, func(i, j int) bool { return less(ranks[order[i]], ranks[order[j]]) })
+			for position, index := range order {
+				points[index] += position
+			}
+		}
+
+		indexes := make([]int, len(ranks))
+		for index := range indexes {
+			indexes[index] = index
+		}
+		sort.SliceStable(indexes, func(i, j int) bool { return points[indexes[i]] < points[indexes[j]] })
+
+		sorted := make([]marketRankType, len(ranks))
+		for position, index := range indexes {
+			sorted[position] = ranks[index]
+			sorted[position].Rank = position + 1
+		}
+		quoteRanks[quoteAsset] = sorted
+	}
+	return quoteRanks
+}
+
+// selectMarkets enables the top ranked eligible markets of every configured quote asset and
+// disables enabled ones that no longer clear the thresholds, leaving markets with active orders alone.
+// The streams restart once after all the changes.
+func selectMarkets() {
+	policy := utils.Config.MarketSelection
+
+	quoteAssets := make(map[string]bool)
+	for _, quoteAsset := range policy.QuoteAssets {
+		quoteAssets[strings.ToUpper(quoteAsset)] = true
+	}
+
+	var markets []models.Market
+	marketListMutex.RLock()
+	for _, market := range marketList {
+		if market.Exchange == "binance" && quoteAssets[market.QuoteAsset] {
+			markets = append(markets, market)
+		}
+	}
+	marketListMutex.RUnlock()
+
+	changed := false
+	for _, ranks := range rankMarkets(markets) {
+		selected := 0
+		for _, ranked := range ranks {
+			market := ranked.Market
+			details := fmt.Sprintf("Rank: %v | Volume: %v %s | Spread: %v%% | Volatility: %v%%",
+				ranked.Rank, utils.TruncateFloat(market.VolumeQuote24h, 0), market.QuoteAsset, ranked.Spread, ranked.Volatility)
+
+			switch {
+			case ranked.Eligible && selected < policy.TopN:
+				selected++
+				if market.Status != "enabled" {
+					saveMarketStatus(market.Pair, market.Exchange, "enabled")
+					changed = true
+					wsBroadcastNotification <- notifications{
+						Type: "market", Title: "*Market Selection* enabled *" + market.Pair + "*", Message: details,
+					}
+				}
+
+			case !ranked.Eligible && market.Status == "enabled":
+				if marketHasActiveOrders(market.Pair, market.Exchange) {
+					continue
+				}
+
+				saveMarketStatus(market.Pair, market.Exchange, "disabled")
+				changed = true
+				wsBroadcastNotification <- notifications{
+					Type: "market", Title: "*Market Selection* disabled *" + market.Pair + "*", Message: details,
+				}
+			}
+		}
+	}
+
+	if changed {
+		restartMarkets("binance")
+	}
+}
+
+// GoSelectMarkets periodically runs the market selection policy when it is switched on in config.yaml.
+func GoSelectMarkets() {
+	if !utils.Config.MarketSelection.Enabled {
+		return
+	}
+
+	interval := utils.Config.MarketSelection.Interval
+	if interval <= 0 {
+		interval = time.Minute * 30
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		selectMarkets()
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"testing"
+)
+
+func TestSelectMarketsKeepsLiquidMarketEnabled(t *testing.T) {
+	utils.Config.MarketSelection.TopN = 1
+	utils.Config.MarketSelection.QuoteAssets = []string{"USDT"}
+	utils.Config.MarketSelection.MinVolumeQuote = 1000000
+	utils.Config.MarketSelection.MaxSpread = 0.2
+	utils.Config.MarketSelection.MinVolatility = 1
+	utils.Config.MarketSelection.MaxVolatility = 0
+
+	// an enabled market whose streams hold the 1m candle's quote volume, far below the 24h threshold
+	market := models.Market{Pair: "SELECTUSDT", Exchange: "binance", QuoteAsset: "USDT",
+		VolumeQuote: 2500, VolumeQuote24h: 5000000, Spread: 0.05, HighPrice: 1.1, LowPrice: 1}
+	market.Status = "enabled"
+	updateMarket(market)
+
+	for run := 1; run <= 2; run++ {
+		ranks := rankMarkets([]models.Market{getMarket("SELECTUSDT", "binance")})["USDT"]
+		if len(ranks) != 1 || !ranks[0].Eligible {
+			t.Fatalf("run %d: liquid market is not eligible: %+v", run, ranks)
+		}
+
+		selectMarkets()
+		if market := getMarket("SELECTUSDT", "binance"); market.Status != "enabled" {
+			t.Fatalf("run %d: liquid market was %s", run, market.Status)
+		}
+
+		// the kline stream moves on to the next candle between runs
+		market = getMarket("SELECTUSDT", "binance")
+		market.VolumeQuote = 1200
+		updateMarket(market)
+	}
+}
@@ -8,9 +8,6 @@ import (
 	"net/http"
 	"strings"
 	"sync"
-	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
@@ -19,12 +16,21 @@ var (
 
 	marketListMutex    = sync.RWMutex{}
 	marketListMapMutex = sync.RWMutex{}
-	wsConnMarketsMutex = sync.RWMutex{}
 
-	wsConnMarkets     = make(map[*websocket.Conn]bool)
 	wsBroadcastMarket = make(chan models.Market, 10240)
 )
 
+const (
+	MarketTrading  = "TRADING"
+	MarketDelisted = "DELISTED"
+)
+
+// isMarketTradable reports whether the exchange still accepts orders on a market; markets
+// loaded before their symbol status was known are assumed tradable.
+func isMarketTradable(market models.Market) bool {
+	return market.TradingStatus == "" || market.TradingStatus == MarketTrading
+}
+
 func getMarket(marketPair, marketExchange string) (market models.Market) {
 	marketKey := 0
 	marketListMapMutex.RLock()
@@ -95,38 +101,32 @@ func restartMarkets(exchange string) {
 func wsHandlerMarkets(httpRes http.ResponseWriter, httpReq *http.Request) {
 	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
 
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
-
-		var unlockedMarketList []models.Market
-		marketListMutex.RLock()
-		for _, market := range marketList {
-			unlockedMarketList = append(unlockedMarketList, market)
-		}
-		marketListMutex.RUnlock()
-
-		wsConnMarketsMutex.Lock()
-		for _, market := range unlockedMarketList {
-			wsConn.WriteJSON(market)
-		}
-		wsConnMarkets[wsConn] = true
-		wsConnMarketsMutex.Unlock()
+		client := wsHubMarkets.register(wsConn, httpReq, sendMarkets)
+		defer client.close()
 
 		for {
 
-			var msg struct {
-				Action, Pair,
-				Exchange string
-			}
-
+			var msg wsSubscriptionMsgType
 			if err := wsConn.ReadJSON(&msg); err != nil {
 				log.Println("wsConn.ReadJSON: ", err)
-				// wsConn.Close()
 				return
 			}
 
+			if client.handle(msg) {
+				continue
+			}
+
+			switch msg.Action {
+			case "autotradekill":
+				setAutoTradeEnabled(false)
+				client.reply(msg.ID, msg.Action, false, nil)
+				continue
+			case "autotraderesume":
+				setAutoTradeEnabled(true)
+				client.reply(msg.ID, msg.Action, true, nil)
+				continue
+			}
+
 			if msg.Pair == "" {
 				continue
 			}
@@ -154,58 +154,67 @@ func wsHandlerMarkets(httpRes http.ResponseWriter, httpReq *http.Request) {
 					}()
 			*/
 			case "enable":
-				oldMarket := getMarket(msg.Pair, msg.Exchange)
-				oldMarket.Status = "enabled"
-				updateMarket(oldMarket)
-				restartMarkets(msg.Exchange)
-				wsBroadcastMarket <- oldMarket
-				if err := utils.SqlDB.Model(&oldMarket).Where("pair = ? and exchange = ?", oldMarket.Pair, oldMarket.Exchange).Updates(
-					map[string]interface{}{"status": "enabled"}).Error; err != nil {
-					log.Println(err.Error())
-				}
+				setMarketStatus(msg.Pair, msg.Exchange, "enabled")
 
 			case "disable":
-				oldMarket := getMarket(msg.Pair, msg.Exchange)
-				oldMarket.Status = "disabled"
-				updateMarket(oldMarket)
-				restartMarkets(msg.Exchange)
-				wsBroadcastMarket <- oldMarket
-				if err := utils.SqlDB.Model(&oldMarket).Where("pair = ? and exchange = ?", oldMarket.Pair, oldMarket.Exchange).Updates(
-					map[string]interface{}{"status": "disabled"}).Error; err != nil {
-					log.Println(err.Error())
-				}
+				setMarketStatus(msg.Pair, msg.Exchange, "disabled")
+
+			case "autotradeon":
+				setMarketAutoTrade(msg.Pair, msg.Exchange, 1)
+
+			case "autotradeoff":
+				setMarketAutoTrade(msg.Pair, msg.Exchange, 0)
+
+			default:
+				continue
 			}
+			client.reply(msg.ID, msg.Action, getMarket(msg.Pair, msg.Exchange), nil)
 		}
 	}
 }
 
-func wsHandlerMarketBroadcast() {
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			wsConnMarketsMutex.Lock()
-			for wsConn := range wsConnMarkets {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnMarkets, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnMarketsMutex.Unlock()
+// sendMarkets queues for a client the markets subscriptions match.
+func sendMarkets(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	var unlockedMarketList []models.Market
+	marketListMutex.RLock()
+	for _, market := range marketList {
+		if subscriptions.matches(market.Pair, market.Exchange, "") {
+			unlockedMarketList = append(unlockedMarketList, market)
 		}
-	}()
+	}
+	marketListMutex.RUnlock()
 
+	for _, market := range unlockedMarketList {
+		client.send("market", fmt.Sprintf("%s-%s", market.Pair, strings.ToLower(market.Exchange)), market)
+	}
+}
+
+// setMarketStatus enables or disables a market, restarting its streams and saving the change.
+func setMarketStatus(pair, exchange, status string) {
+	saveMarketStatus(pair, exchange, status)
+	restartMarkets(exchange)
+}
+
+// saveMarketStatus enables or disables a market and saves the change, leaving the streams to a
+// restartMarkets of the caller so several changes restart them once.
+func saveMarketStatus(pair, exchange, status string) {
+	oldMarket := getMarket(pair, exchange)
+	oldMarket.Status = status
+	updateMarket(oldMarket)
+	wsBroadcastMarket <- oldMarket
+	if err := utils.SqlDB.Model(&oldMarket).Where("pair = ? and exchange = ?", oldMarket.Pair, oldMarket.Exchange).Updates(
+		map[string]interface{}{"status": status}).Error; err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func wsHandlerMarketBroadcast() {
 	go func() {
 		for market := range wsBroadcastMarket {
-			wsConnMarketsMutex.Lock()
-			for wsConn := range wsConnMarkets {
-				if err := wsConn.WriteJSON(market); err != nil {
-					delete(wsConnMarkets, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnMarketsMutex.Unlock()
+			wsHubMarkets.broadcast("market", fmt.Sprintf("%s-%s", market.Pair, strings.ToLower(market.Exchange)), market,
+				func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+					return market, subscriptions.matches(market.Pair, market.Exchange, "")
+				})
 		}
 	}()
 }
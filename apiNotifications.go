@@ -1,67 +1,289 @@
 package main
 
 import (
+	"backpocket/models"
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
-	wsConnNotificationsMutex = sync.RWMutex{}
-
-	wsConnNotifications     = make(map[*websocket.Conn]bool)
 	wsBroadcastNotification = make(chan notifications, 10240)
 )
 
 type notifications struct {
+	ID uint64
+
 	Type, Title, Message string
+
+	Severity, Source, Pair string
+	OrderID, OpportunityID uint64
 }
 
-func wsHandlerNotifications(httpRes http.ResponseWriter, httpReq *http.Request) {
-	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
+// notificationMsgType is a request received on the notifications websocket, either an alert
+// action or marking notifications as read or acknowledged.
+type notificationMsgType struct {
+	Action string
+	Pair   string
+	Status string
+	IDs    []uint64
+	// All marks every notification, a read or ack without IDs being refused as it is on the REST endpoint
+	All   bool
+	Alert models.Alert
 
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
+	// Event and Subscriptions complete a subscribe or unsubscribe by Pair, the event being a
+	// notification type, severity or source, ID is echoed in the response and Seq starts a resync
+	ID, Event     string
+	Seq           uint64
+	Subscriptions []wsSubscriptionType
+}
+
+// saveNotification stores a notification, filling in the severity and source it was sent without,
+// and returns it with its ID so clients can acknowledge it. A replay stores nothing.
+func saveNotification(notify notifications) notifications {
+	if notify.Severity == "" {
+		notify.Severity = "info"
+	}
+
+	if notify.Source == "" {
+		switch {
+		case strings.Contains(notify.Title, "Binance"):
+			notify.Source = "binance"
+		case strings.Contains(notify.Title, "Crex24"):
+			notify.Source = "crex24"
+		default:
+			notify.Source = "strategy"
+		}
+	}
 
-		wsConnNotificationsMutex.Lock()
-		wsConnNotifications[wsConn] = true
-		wsConnNotificationsMutex.Unlock()
+	if utils.SqlDB == nil || isReplayMode() {
+		return notify
+	}
 
+	notification := models.Notification{
+		Type: notify.Type, Severity: notify.Severity, Source: notify.Source,
+		Title: notify.Title, Message: notify.Message, Pair: notify.Pair,
+		OrderID: notify.OrderID, OpportunityID: notify.OpportunityID,
+	}
+	notification.Status = models.NotificationUnread
+	if err := utils.SqlDB.Create(&notification).Error; err != nil {
+		log.Println(err.Error())
+		return notify
 	}
+
+	notify.ID = notification.ID
+	return notify
 }
 
-func wsHandlerNotificationBroadcast() {
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			wsConnNotificationsMutex.Lock()
-			for wsConn := range wsConnNotifications {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnNotifications, wsConn)
-					wsConn.Close()
+// markNotifications sets the read or acknowledged state of the given notifications, or of every
+// unacknowledged one when ids is empty.
+func markNotifications(ids []uint64, status string) error {
+	if status != models.NotificationRead && status != models.NotificationAcknowledged {
+		status = models.NotificationAcknowledged
+	}
+
+	updates := map[string]interface{}{"status": status, "updatedate": time.Now()}
+	if status == models.NotificationAcknowledged {
+		updates["acknowledgedate"] = time.Now()
+	}
+
+	tx := utils.SqlDB.Model(&models.Notification{}).Where("status <> ?", models.NotificationAcknowledged)
+	if len(ids) > 0 {
+		tx = tx.Where("id IN ?", ids)
+	}
+	return tx.Updates(updates).Error
+}
+
+// unreadNotifications returns the unread notifications, oldest first, that a new client has missed.
+func unreadNotifications() (notificationList []models.Notification) {
+	if utils.SqlDB == nil {
+		return
+	}
+
+	if err := utils.SqlDB.Where("status = ?", models.NotificationUnread).Order("createdate desc").Limit(200).Find(&notificationList).Error; err != nil {
+		log.Println(err.Error())
+	}
+
+	for i, j := 0, len(notificationList)-1; i < j; i, j = i+1, j-1 {
+		notificationList[i], notificationList[j] = notificationList[j], notificationList[i]
+	}
+	return
+}
+
+// sendUnreadNotifications queues for a client the unread notifications subscriptions match.
+func sendUnreadNotifications(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	for _, notification := range unreadNotifications() {
+		if !subscriptions.matches(notification.Pair, "", "", notification.Type, notification.Severity, notification.Source) {
+			continue
+		}
+		client.send("notification", "", notifications{
+			ID: notification.ID, Type: notification.Type, Title: notification.Title, Message: notification.Message,
+			Severity: notification.Severity, Source: notification.Source, Pair: notification.Pair,
+			OrderID: notification.OrderID, OpportunityID: notification.OpportunityID,
+		})
+	}
+}
+
+func wsHandlerNotifications(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
+
+		client := wsHubNotifications.register(wsConn, httpReq, sendUnreadNotifications)
+		defer client.close()
+
+		for {
+			var msg notificationMsgType
+			if err := wsConn.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			if client.handle(wsSubscriptionMsgType{ID: msg.ID, Action: msg.Action, Pair: msg.Pair, Event: msg.Event,
+				Seq: msg.Seq, Subscriptions: msg.Subscriptions}) {
+				continue
+			}
+
+			switch {
+			case strings.HasPrefix(msg.Action, "alert"):
+				client.respond(msg.ID, handleAlertMessage(msg))
+
+			case msg.Action == "read" || msg.Action == "ack":
+				status := models.NotificationAcknowledged
+				if msg.Action == "read" {
+					status = models.NotificationRead
+				}
+
+				if len(msg.IDs) == 0 && !msg.All {
+					client.reply(msg.ID, msg.Action, nil, fmt.Errorf("Missing IDs, or All"))
+					continue
 				}
+				client.reply(msg.ID, msg.Action, msg.IDs, markNotifications(msg.IDs, status))
 			}
-			wsConnNotificationsMutex.Unlock()
 		}
-	}()
+	}
+}
 
+func wsHandlerNotificationBroadcast() {
 	go func() {
 
 		for notify := range wsBroadcastNotification {
-			wsConnNotificationsMutex.Lock()
-			for wsConn := range wsConnNotifications {
-				if err := wsConn.WriteJSON(notify); err != nil {
-					delete(wsConnNotifications, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnNotificationsMutex.Unlock()
+			notify = saveNotification(notify)
+			dispatchNotifiers(notify)
+
+			wsHubNotifications.broadcast("notification", "", notify, func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+				return notify, subscriptions.matches(notify.Pair, "", "", notify.Type, notify.Severity, notify.Source)
+			})
 		}
 	}()
 }
+
+type notificationPageType struct {
+	Total int64
+	Page  int
+	Limit int
+	Rows  []models.Notification
+}
+
+func restHandlerNotifications(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	if utils.SqlDB == nil {
+		http.Error(httpRes, "Database not configured", http.StatusInternalServerError)
+		return
+	}
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	var searchText []string
+	var searchParams []interface{}
+	for _, column := range []string{"status", "type", "severity", "source", "pair"} {
+		if value := query.Get(column); value != "" {
+			searchText = append(searchText, column+" = ?")
+			searchParams = append(searchParams, value)
+		}
+	}
+
+	if starttime := query.Get("starttime"); starttime != "" {
+		searchText = append(searchText, "createdate >= ?::timestamp")
+		searchParams = append(searchParams, starttime)
+	}
+
+	if endtime := query.Get("endtime"); endtime != "" {
+		searchText = append(searchText, "createdate <= ?::timestamp")
+		searchParams = append(searchParams, endtime)
+	}
+
+	result := notificationPageType{Page: page, Limit: limit}
+	tx := utils.SqlDB.Model(&models.Notification{}).Where(strings.Join(searchText, " AND "), searchParams...)
+	if err := tx.Count(&result.Total).Error; err != nil {
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Order("createdate desc").Offset((page - 1) * limit).Limit(limit).Find(&result.Rows).Error; err != nil {
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(result)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
+
+func restHandlerAcknowledgeNotifications(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	if utils.SqlDB == nil {
+		http.Error(httpRes, "Database not configured", http.StatusInternalServerError)
+		return
+	}
+
+	var ids []uint64
+	for _, idVar := range strings.Split(query.Get("id"), ",") {
+		if idVar == "" {
+			continue
+		}
+
+		id, err := strconv.ParseUint(idVar, 10, 64)
+		if err != nil {
+			http.Error(httpRes, "Invalid id parameter", http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) == 0 && query.Get("all") != "true" {
+		http.Error(httpRes, "Missing id parameter, or all=true", http.StatusBadRequest)
+		return
+	}
+
+	if err := markNotifications(ids, query.Get("status")); err != nil {
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(ids)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"backpocket/utils"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// notifier delivers a notification to one outbound channel.
+type notifier interface {
+	send(notify notifications) error
+}
+
+type notifierChannel struct {
+	config   utils.NotifierConfig
+	notifier notifier
+	queue    chan notifications
+	sent     []time.Time
+}
+
+var notifierChannels []*notifierChannel
+
+type telegramNotifier struct{ config utils.NotifierConfig }
+type slackNotifier struct{ config utils.NotifierConfig }
+type webhookNotifier struct{ config utils.NotifierConfig }
+type smtpNotifier struct{ config utils.NotifierConfig }
+
+var (
+	// telegramMarkdownEscaper escapes the characters Telegram's Markdown parses, so exchange errors
+	// like "Filter failure: MIN_NOTIONAL" are delivered rather than refused as unparsable
+	telegramMarkdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+
+	// smtpHeaderSanitizer keeps a subject on its one header line
+	smtpHeaderSanitizer = strings.NewReplacer("\r", " ", "\n", " ")
+)
+
+// postNotifierJSON posts payload to url and treats any non 2xx response as a failure.
+func postNotifierJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	httpClient := http.Client{Timeout: time.Duration(time.Second * 10)}
+	httpResponse, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		return fmt.Errorf("%s responded with %s", url, httpResponse.Status)
+	}
+	return nil
+}
+
+func (n telegramNotifier) send(notify notifications) error {
+	baseURL := n.config.URL
+	if baseURL == "" {
+		baseURL = "https://api.telegram.org"
+	}
+
+	return postNotifierJSON(fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimSuffix(baseURL, "/"), n.config.Token), map[string]string{
+		"chat_id":    n.config.ChatID,
+		"text":       notify.Title + "\n" + telegramMarkdownEscaper.Replace(notify.Message),
+		"parse_mode": "Markdown",
+	})
+}
+
+func (n slackNotifier) send(notify notifications) error {
+	return postNotifierJSON(n.config.URL, map[string]string{
+		"text": notify.Title + "\n" + notify.Message,
+	})
+}
+
+func (n webhookNotifier) send(notify notifications) error {
+	return postNotifierJSON(n.config.URL, notify)
+}
+
+func (n smtpNotifier) send(notify notifications) error {
+	subject := smtpHeaderSanitizer.Replace(strings.ReplaceAll(notify.Title, "*", ""))
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		n.config.From, strings.Join(n.config.To, ", "), subject, notify.Message)
+
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+	return smtp.SendMail(fmt.Sprintf("%s:%d", n.config.Host, n.config.Port), auth, n.config.From, n.config.To, []byte(message))
+}
+
+// routes reports whether the channel takes notifications of this Type; no Types means every Type.
+func (channel *notifierChannel) routes(notifyType string) bool {
+	if len(channel.config.Types) == 0 {
+		return true
+	}
+
+	for _, routedType := range channel.config.Types {
+		if strings.EqualFold(routedType, notifyType) || (routedType == "default" && notifyType == "") {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForRateLimit blocks until sending stays within the channel's per minute limit.
+func (channel *notifierChannel) waitForRateLimit() {
+	if channel.config.RateLimit <= 0 {
+		return
+	}
+
+	for {
+		for len(channel.sent) > 0 && time.Since(channel.sent[0]) >= time.Minute {
+			channel.sent = channel.sent[1:]
+		}
+
+		if len(channel.sent) < channel.config.RateLimit {
+			channel.sent = append(channel.sent, time.Now())
+			return
+		}
+		time.Sleep(time.Minute - time.Since(channel.sent[0]))
+	}
+}
+
+// run delivers the queued notifications of a channel, retrying failures with a doubling backoff.
+func (channel *notifierChannel) run() {
+	for notify := range channel.queue {
+		channel.waitForRateLimit()
+
+		backoff := time.Second
+		for attempt := 0; ; attempt++ {
+			err := channel.notifier.send(notify)
+			if err == nil {
+				break
+			}
+
+			if attempt >= channel.config.Retries {
+				log.Printf("Notifier %s failed to deliver %q: %v \n", channel.config.Name, notify.Title, err)
+				break
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// startNotifiers builds the outbound channels configured under notifiers in config.yaml.
+func startNotifiers() {
+	for _, config := range utils.Config.Notifiers {
+		channel := &notifierChannel{config: config, queue: make(chan notifications, 1024)}
+
+		switch strings.ToLower(config.Kind) {
+		case "telegram":
+			channel.notifier = telegramNotifier{config: config}
+		case "slack":
+			channel.notifier = slackNotifier{config: config}
+		case "webhook":
+			channel.notifier = webhookNotifier{config: config}
+		case "smtp", "email":
+			channel.notifier = smtpNotifier{config: config}
+		default:
+			log.Printf("Unknown notifier kind %q for %s \n", config.Kind, config.Name)
+			continue
+		}
+
+		if channel.config.Name == "" {
+			channel.config.Name = config.Kind
+		}
+
+		notifierChannels = append(notifierChannels, channel)
+		go channel.run()
+	}
+}
+
+// dispatchNotifiers queues a notification on every channel routed for its Type, dropping it when a
+// channel is too far behind so the websocket broadcast never blocks. Nothing leaves a replay.
+func dispatchNotifiers(notify notifications) {
+	if isReplayMode() {
+		return
+	}
+
+	for _, channel := range notifierChannels {
+		if !channel.routes(notify.Type) {
+			continue
+		}
+
+		select {
+		case channel.queue <- notify:
+		default:
+			log.Printf("Notifier %s queue is full, dropping %q \n", channel.config.Name, notify.Title)
+		}
+	}
+}
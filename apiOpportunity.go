@@ -5,6 +5,7 @@ import (
 	"backpocket/utils"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -164,6 +165,7 @@ type opportunityType struct {
 	Exchange   string
 	Stoploss   float64
 	Takeprofit float64
+	Score      float64
 	Analysis   map[string]interface{}
 }
 
@@ -177,6 +179,10 @@ func analyseOpportunity(analysis analysisType, timeframe string, price float64)
 	}
 
 	market := getMarket(analysis.Pair, analysis.Exchange)
+	if !isMarketTradable(market) {
+		return
+	}
+
 	if price == 0 {
 		price = market.Price
 	}
@@ -202,19 +208,30 @@ func analyseOpportunity(analysis analysisType, timeframe string, price float64)
 	opportunity.Timeframe = timeframe
 	opportunity.Price = price
 
-	isCheckLong := checkIfLong(price, lowerInterval, middleInterval, upperInterval)
+	threshold := utils.Config.Opportunity.Threshold
+	orderbookAnalytics := getOrderbookAnalytics(analysis.Pair, analysis.Exchange)
 
 	//Check for Long // Buy Opportunity
-	if isCheckLong {
+	longScore, longConditions := checkIfLong(price, orderbookAnalytics, lowerInterval, middleInterval, upperInterval)
+	if longScore >= threshold {
 		opportunity.Action = "BUY"
+		opportunity.Score = longScore
 	}
 
 	// -- -- --
 
 	//Check for Short // Sell Opportunity
-	isCheckShort := checkIfShort(price, lowerInterval, middleInterval, upperInterval)
-	if isCheckShort {
+	shortScore, shortConditions := checkIfShort(price, orderbookAnalytics, lowerInterval, middleInterval, upperInterval)
+	if shortScore >= threshold && shortScore >= longScore {
 		opportunity.Action = "SELL"
+		opportunity.Score = shortScore
+	}
+
+	if opportunity.Action == "" {
+		opportunity.Score = longScore
+		if shortScore > longScore {
+			opportunity.Score = shortScore
+		}
 	}
 
 	switch opportunity.Action {
@@ -226,10 +243,17 @@ func analyseOpportunity(analysis analysisType, timeframe string, price float64)
 		opportunity.Takeprofit = utils.TruncateFloat(price*0.95, 8)
 	}
 
-	// opportunity.Analysis = map[string]interface{}{
-	// 	"Buy":  buyAnalysis,
-	// 	"Sell": sellAnalysis,
-	// }
+	if target := chartPatternTarget(opportunity.Action, price, lowerInterval, middleInterval); target > 0 {
+		opportunity.Takeprofit = target
+	} else if target := fibonacciExtensionTarget(opportunity.Action, price, lowerInterval); target > 0 {
+		opportunity.Takeprofit = target
+	}
+
+	opportunity.Analysis = map[string]interface{}{
+		"Threshold": threshold,
+		"Buy":       opportunitySideType{Score: longScore, Conditions: longConditions},
+		"Sell":      opportunitySideType{Score: shortScore, Conditions: shortConditions},
+	}
 
 	if market.Closed == 1 {
 		opportunityMutex.Lock()
@@ -241,82 +265,485 @@ func analyseOpportunity(analysis analysisType, timeframe string, price float64)
 	return
 }
 
-func checkIfLong(currentPrice float64, summaryLower, summaryMiddle, summaryUpper utils.Summary) bool {
+// chartPatternTarget returns the nearest measured-move target of a confirmed chart pattern
+// breaking out in the direction of action, or 0 when there is none.
+func chartPatternTarget(action string, price float64, summaries ...utils.Summary) (target float64) {
+	for _, summary := range summaries {
+		for _, pattern := range summary.ChartPatterns {
+			if pattern.State != utils.PatternConfirmed {
+				continue
+			}
+
+			switch {
+			case action == "BUY" && pattern.Direction == utils.Bullish && pattern.Target > price:
+				if target == 0 || pattern.Target < target {
+					target = pattern.Target
+				}
+			case action == "SELL" && pattern.Direction == utils.Bearish && pattern.Target < price && pattern.Target > 0:
+				if target == 0 || pattern.Target > target {
+					target = pattern.Target
+				}
+			}
+		}
+	}
+	return
+}
+
+// fibonacciExtensionTarget returns the first fibonacci extension beyond price when the anchored
+// swing runs in the direction of action, or 0 when there is none.
+func fibonacciExtensionTarget(action string, price float64, summary utils.Summary) float64 {
+	for _, ratio := range []string{"1.272", "1.618", "2.618"} {
+		extension := summary.Fibonacci.Extensions[ratio]
+		switch {
+		case action == "BUY" && summary.Fibonacci.Direction == utils.Bullish && extension > price:
+			return extension
+		case action == "SELL" && summary.Fibonacci.Direction == utils.Bearish && extension < price && extension > 0:
+			return extension
+		}
+	}
+	return 0
+}
+
+// fibonacciLevel returns the retracement level nearest to the swing low (nearLow) or the swing high.
+// Retracements are measured back from the end of the swing, so which ratio sits near the low
+// depends on whether the anchored swing ran up or down.
+func fibonacciLevel(summary utils.Summary, nearLow bool) float64 {
+	ratio := "0.786"
+	if nearLow == (summary.Fibonacci.Direction == utils.Bearish) {
+		ratio = "0.236"
+	}
+	return summary.RetracementLevels[ratio]
+}
+
+// supportResistanceZone merges the swing zones and pivots of the given summaries and returns the
+// strongest zone of zoneType that price is trading in, provided it was touched more than once.
+func supportResistanceZone(price float64, zoneType string, summaries ...utils.Summary) (zone utils.SRZone, found bool) {
+	intervals := make(map[string]utils.Summary)
+	for _, summary := range summaries {
+		intervals[summary.Timeframe] = summary
+	}
+
+	tolerance := utils.Config.ChartPatterns.ZoneTolerance
+	zones := utils.MergeTimeframeZones(intervals, price, tolerance)
+	if zone, found = utils.NearestZone(zones, price, zoneType, tolerance); found && zone.Touches < 2 {
+		found = false
+	}
+	return
+}
+
+// volumeProfileZone returns the strongest volume profile level of zoneType, the point of control, a
+// value area edge or a high volume node, that price is trading in or within the zone tolerance of.
+func volumeProfileZone(price float64, zoneType string, summaries ...utils.Summary) (zone utils.SRZone, found bool) {
+	var zones []utils.SRZone
+	for _, summary := range summaries {
+		zones = append(zones, utils.VolumeProfileZones(summary.VolumeProfile, price, summary.Timeframe)...)
+	}
+	return utils.NearestZone(zones, price, zoneType, utils.Config.ChartPatterns.ZoneTolerance)
+}
+
+// opportunityCondition is the outcome of one rule of the long or short check and what it contributed to the score.
+type opportunityCondition struct {
+	Passed  bool
+	Weight  float64
+	Score   float64
+	Details []conditionDetail
+}
+
+// conditionDetail is the input a rule compared against its threshold on a single timeframe.
+type conditionDetail struct {
+	Timeframe string
+	Input     interface{}
+	Operator  string
+	Threshold interface{}
+	Passed    bool
+}
+
+// opportunitySideType is the score and per-condition breakdown of the long or short check.
+type opportunitySideType struct {
+	Score      float64
+	Conditions map[string]opportunityCondition
+}
+
+// scoreConditions weighs each condition with its configured weight and returns the share of the
+// total weight that passed, between 0 and 1, together with the per-condition breakdown.
+func scoreConditions(checks map[string]bool, details map[string][]conditionDetail) (score float64, conditions map[string]opportunityCondition) {
+	var totalWeight float64
+	conditions = make(map[string]opportunityCondition)
+	for name, passed := range checks {
+		weight, ok := utils.Config.Opportunity.Weights[name]
+		if !ok {
+			weight = 1
+		}
+
+		condition := opportunityCondition{Passed: passed, Weight: weight, Details: details[name]}
+		if passed {
+			condition.Score = weight
+			score += weight
+		}
+		conditions[name] = condition
+		totalWeight += weight
+	}
+
+	if totalWeight == 0 {
+		return 0, conditions
+	}
+	return utils.TruncateFloat(score/totalWeight, 3), conditions
+}
+
+// allDetailsPassed reports whether every timeframe of a rule passed.
+func allDetailsPassed(details []conditionDetail) bool {
+	for _, detail := range details {
+		if !detail.Passed {
+			return false
+		}
+	}
+	return len(details) > 0
+}
+
+func checkIfLong(currentPrice float64, orderbookAnalytics orderbookAnalyticsType, summaryLower, summaryMiddle, summaryUpper utils.Summary) (float64, map[string]opportunityCondition) {
 	checkLong := map[string]bool{
-		"rsi":       true,
-		"fib":       true,
-		"trend":     true,
-		"support":   true,
-		"bollinger": true,
+		"rsi":           false,
+		"fib":           false,
+		"trend":         false,
+		"support":       false,
+		"bollinger":     false,
+		"imbalance":     false,
+		"wall":          false,
+		"volumeprofile": false,
+	}
+
+	details := make(map[string][]conditionDetail)
+	for _, summary := range []utils.Summary{summaryLower, summaryMiddle, summaryUpper} {
+		fibLevel := fibonacciLevel(summary, true)
+		details["rsi"] = append(details["rsi"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: summary.RSI, Operator: "<", Threshold: 50, Passed: summary.RSI < 50})
+		details["fib"] = append(details["fib"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: currentPrice, Operator: "<", Threshold: fibLevel, Passed: currentPrice < fibLevel})
+		details["trend"] = append(details["trend"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: summary.Trend, Operator: "==", Threshold: "Bearish", Passed: summary.Trend == "Bearish"})
+		details["support"] = append(details["support"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: summary.SMA50.Support, Operator: "==", Threshold: summaryLower.SMA50.Support,
+			Passed: summary.SMA50.Support == summaryLower.SMA50.Support})
+	}
+	details["bollinger"] = []conditionDetail{{Timeframe: summaryLower.Timeframe,
+		Input: summaryLower.Candle.Low, Operator: "<", Threshold: summaryLower.BollingerBands["lower"],
+		Passed: summaryLower.Candle.Low < summaryLower.BollingerBands["lower"]}}
+	details["imbalance"], details["wall"] = orderbookConditionDetails(orderbookAnalytics, true)
+
+	if summaryLower.RSI == 0 || summaryUpper.RSI == 0 || summaryMiddle.RSI == 0 {
+		return scoreConditions(checkLong, details)
+	}
+
+	checkLong["rsi"] = allDetailsPassed(details["rsi"])
+	checkLong["fib"] = allDetailsPassed(details["fib"])
+	checkLong["trend"] = allDetailsPassed(details["trend"])
+	checkLong["bollinger"] = allDetailsPassed(details["bollinger"])
+	checkLong["imbalance"] = allDetailsPassed(details["imbalance"])
+	checkLong["wall"] = allDetailsPassed(details["wall"])
+
+	checkLong["support"] = allDetailsPassed(details["support"])
+	if !checkLong["support"] {
+		zone, found := supportResistanceZone(currentPrice, utils.ZoneSupport, summaryLower, summaryMiddle, summaryUpper)
+		details["support"] = append(details["support"], conditionDetail{Timeframe: "zone",
+			Input: currentPrice, Operator: "within", Threshold: zone, Passed: found})
+		checkLong["support"] = found
 	}
 
+	zone, found := volumeProfileZone(currentPrice, utils.ZoneSupport, summaryLower, summaryMiddle, summaryUpper)
+	details["volumeprofile"] = []conditionDetail{{Timeframe: "volume profile",
+		Input: currentPrice, Operator: "within", Threshold: zone, Passed: found}}
+	checkLong["volumeprofile"] = found
+
+	return scoreConditions(checkLong, details)
+}
+
+func checkIfShort(currentPrice float64, orderbookAnalytics orderbookAnalyticsType, summaryLower, summaryMiddle, summaryUpper utils.Summary) (float64, map[string]opportunityCondition) {
+	checkShort := map[string]bool{
+		"rsi":           false,
+		"fib":           false,
+		"trend":         false,
+		"bollinger":     false,
+		"resistance":    false,
+		"imbalance":     false,
+		"wall":          false,
+		"volumeprofile": false,
+	}
+
+	details := make(map[string][]conditionDetail)
+	for _, summary := range []utils.Summary{summaryLower, summaryMiddle, summaryUpper} {
+		fibLevel := fibonacciLevel(summary, false)
+		details["rsi"] = append(details["rsi"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: summary.RSI, Operator: ">", Threshold: 50, Passed: summary.RSI > 50})
+		details["fib"] = append(details["fib"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: currentPrice, Operator: ">", Threshold: fibLevel, Passed: currentPrice > fibLevel})
+		details["trend"] = append(details["trend"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: summary.Trend, Operator: "==", Threshold: "Bullish", Passed: summary.Trend == "Bullish"})
+		details["resistance"] = append(details["resistance"], conditionDetail{Timeframe: summary.Timeframe,
+			Input: summary.SMA50.Resistance, Operator: "==", Threshold: summaryLower.SMA50.Resistance,
+			Passed: summary.SMA50.Resistance == summaryLower.SMA50.Resistance})
+	}
+	details["bollinger"] = []conditionDetail{{Timeframe: summaryLower.Timeframe,
+		Input: summaryLower.Candle.High, Operator: ">", Threshold: summaryLower.BollingerBands["upper"],
+		Passed: summaryLower.Candle.High > summaryLower.BollingerBands["upper"]}}
+	details["imbalance"], details["wall"] = orderbookConditionDetails(orderbookAnalytics, false)
+
 	if summaryLower.RSI == 0 || summaryUpper.RSI == 0 || summaryMiddle.RSI == 0 {
-		return false
+		return scoreConditions(checkShort, details)
 	}
 
-	if checkLong["rsi"] {
-		checkLong["rsi"] = summaryLower.RSI < 50 &&
-			summaryMiddle.RSI < 50 &&
-			summaryUpper.RSI < 50
+	checkShort["rsi"] = allDetailsPassed(details["rsi"])
+	checkShort["fib"] = allDetailsPassed(details["fib"])
+	checkShort["trend"] = allDetailsPassed(details["trend"])
+	checkShort["bollinger"] = allDetailsPassed(details["bollinger"])
+	checkShort["imbalance"] = allDetailsPassed(details["imbalance"])
+	checkShort["wall"] = allDetailsPassed(details["wall"])
+
+	checkShort["resistance"] = allDetailsPassed(details["resistance"])
+	if !checkShort["resistance"] {
+		zone, found := supportResistanceZone(currentPrice, utils.ZoneResistance, summaryLower, summaryMiddle, summaryUpper)
+		details["resistance"] = append(details["resistance"], conditionDetail{Timeframe: "zone",
+			Input: currentPrice, Operator: "within", Threshold: zone, Passed: found})
+		checkShort["resistance"] = found
 	}
-	if checkLong["fib"] {
-		checkLong["fib"] = currentPrice < summaryLower.RetracementLevels["0.786"] &&
-			currentPrice < summaryMiddle.RetracementLevels["0.786"] &&
-			currentPrice < summaryUpper.RetracementLevels["0.786"]
+
+	zone, found := volumeProfileZone(currentPrice, utils.ZoneResistance, summaryLower, summaryMiddle, summaryUpper)
+	details["volumeprofile"] = []conditionDetail{{Timeframe: "volume profile",
+		Input: currentPrice, Operator: "within", Threshold: zone, Passed: found}}
+	checkShort["volumeprofile"] = found
+
+	return scoreConditions(checkShort, details)
+}
+
+// opportunityExplainType lays out why analyseOpportunity did or did not take an action.
+type opportunityExplainType struct {
+	Pair       string
+	Exchange   string
+	Timeframe  string
+	Timeframes []string
+	Price      float64
+	Action     string
+	Score      float64
+	Threshold  float64
+	Buy        opportunitySideType
+	Sell       opportunitySideType
+}
+
+// explainOpportunity runs analyseOpportunity and returns the inputs, thresholds and outcome of every rule.
+func explainOpportunity(analysis analysisType, timeframe string, price float64) (explain opportunityExplainType) {
+	opportunity := analyseOpportunity(analysis, timeframe, price)
+
+	explain.Pair = opportunity.Pair
+	explain.Exchange = opportunity.Exchange
+	explain.Timeframe = opportunity.Timeframe
+	explain.Timeframes = TimeframeMaps[opportunity.Timeframe]
+	explain.Price = opportunity.Price
+	explain.Action = opportunity.Action
+	explain.Score = opportunity.Score
+	explain.Threshold, _ = opportunity.Analysis["Threshold"].(float64)
+	explain.Buy, _ = opportunity.Analysis["Buy"].(opportunitySideType)
+	explain.Sell, _ = opportunity.Analysis["Sell"].(opportunitySideType)
+	return
+}
+
+// retrieveOpportunityExplain fetches a fresh analysis of pair for timeframe and explains it.
+func retrieveOpportunityExplain(pair, exchange, timeframe string, price float64) (explain opportunityExplainType, err error) {
+	if exchange == "" {
+		exchange = "binance"
 	}
-	if checkLong["trend"] {
-		checkLong["trend"] = summaryLower.Trend == "Bearish" &&
-			summaryMiddle.Trend == "Bearish" &&
-			summaryUpper.Trend == "Bearish"
+
+	if len(TimeframeMaps[timeframe]) != 3 {
+		timeframe = DefaultTimeframe
 	}
 
-	if checkLong["bollinger"] {
-		checkLong["bollinger"] = summaryLower.Candle.Low < summaryLower.BollingerBands["lower"]
+	intervals := strings.Join(TimeframeMaps[timeframe], ",") + ",1m"
+	analysis, err := retrieveMarketPairAnalysis(pair, exchange, "", "", "", intervals)
+	if err != nil {
+		return
 	}
+	explain = explainOpportunity(analysis, timeframe, price)
+	return
+}
 
-	checkLong["support"] =
-		summaryLower.SMA50.Support == summaryMiddle.SMA50.Support &&
-			summaryLower.SMA50.Support == summaryUpper.SMA50.Support
+func restHandlerExplainOpportunity(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
 
-	return checkLong["fib"] && checkLong["bollinger"] && checkLong["trend"] && checkLong["rsi"] && checkLong["support"]
+	pair := query.Get("pair")
+	exchange := query.Get("exchange")
+	timeframe := query.Get("timeframe")
+	marketPriceVar := query.Get("marketprice")
+
+	marketPrice, err := strconv.ParseFloat(marketPriceVar, 64)
+	if err != nil {
+		marketPrice = 0
+	}
+
+	if pair == "" {
+		http.Error(httpRes, "Missing pair parameter", http.StatusBadRequest)
+		return
+	}
+
+	explain, err := retrieveOpportunityExplain(pair, exchange, timeframe, marketPrice)
+	if err != nil {
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(explain)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
 }
 
-func checkIfShort(currentPrice float64, summaryLower, summaryMiddle, summaryUpper utils.Summary) bool {
-	checkShort := map[string]bool{
-		"rsi":        true,
-		"fib":        true,
-		"trend":      true,
-		"bollinger":  true,
-		"resistance": true,
+// opportunityOrderPercentages converts the absolute stoploss and takeprofit of an opportunity into
+// the percentages from price that models.Order uses.
+func opportunityOrderPercentages(opportunity models.Opportunity, price float64) (stoploss, takeprofit float64) {
+	if price == 0 {
+		return
 	}
 
-	if summaryLower.RSI == 0 || summaryUpper.RSI == 0 || summaryMiddle.RSI == 0 {
-		return false
+	switch opportunity.Action {
+	case "BUY":
+		stoploss = (price - opportunity.Stoploss) / price * 100
+		takeprofit = (opportunity.Takeprofit - price) / price * 100
+	case "SELL":
+		stoploss = (opportunity.Stoploss - price) / price * 100
+		takeprofit = (price - opportunity.Takeprofit) / price * 100
+	}
+
+	if opportunity.Stoploss == 0 || stoploss < 0 {
+		stoploss = 0
+	}
+	if opportunity.Takeprofit == 0 || takeprofit < 0 {
+		takeprofit = 0
+	}
+	return utils.TruncateFloat(stoploss, 3), utils.TruncateFloat(takeprofit, 3)
+}
+
+// executeOpportunity places an order of quantity for a stored opportunity at price, or at the
+// opportunity price when price is 0, carrying its stoploss and takeprofit over to the order.
+func executeOpportunity(opportunityID uint64, quantity, price float64) (opportunity models.Opportunity, err error) {
+	if utils.SqlDB == nil {
+		err = fmt.Errorf("Database not configured")
+		return
 	}
 
-	if checkShort["rsi"] {
-		checkShort["rsi"] = summaryLower.RSI > 50 &&
-			summaryMiddle.RSI > 50 &&
-			summaryUpper.RSI > 50
+	if opportunityID == 0 || quantity <= 0 {
+		err = fmt.Errorf("Opportunity ID and a positive quantity are required")
+		return
 	}
-	if checkShort["fib"] {
-		checkShort["fib"] = currentPrice > summaryLower.RetracementLevels["0.236"] &&
-			currentPrice > summaryMiddle.RetracementLevels["0.236"] &&
-			currentPrice > summaryUpper.RetracementLevels["0.236"]
+
+	if err = utils.SqlDB.Where("id = ?", opportunityID).First(&opportunity).Error; err != nil {
+		err = fmt.Errorf("Opportunity %v not found: %v", opportunityID, err)
+		return
 	}
-	if checkShort["trend"] {
-		checkShort["trend"] = summaryLower.Trend == "Bullish" &&
-			summaryMiddle.Trend == "Bullish" &&
-			summaryUpper.Trend == "Bullish"
+
+	if opportunity.Status == models.OpportunityTaken {
+		err = fmt.Errorf("Opportunity %v was already taken by order %v", opportunityID, opportunity.OrderID)
+		return
 	}
 
-	if checkShort["bollinger"] {
-		checkShort["bollinger"] = summaryLower.Candle.High > summaryLower.BollingerBands["upper"]
+	if market := getMarket(opportunity.Pair, opportunity.Exchange); !isMarketTradable(market) {
+		err = fmt.Errorf("Market %s is not trading: %s", opportunity.Pair, market.TradingStatus)
+		return
 	}
 
-	checkShort["resistance"] =
-		summaryLower.SMA50.Resistance == summaryMiddle.SMA50.Resistance &&
-			summaryLower.SMA50.Resistance == summaryUpper.SMA50.Resistance
+	if price == 0 {
+		price = opportunity.Price
+	}
+
+	if err = checkSlippage(opportunity.Pair, opportunity.Exchange, opportunity.Action, quantity); err != nil {
+		return
+	}
 
-	return checkShort["fib"] && checkShort["bollinger"] && checkShort["trend"] && checkShort["rsi"] && checkShort["resistance"]
+	stoploss, takeprofit := opportunityOrderPercentages(opportunity, price)
+	return placeOpportunityOrder(opportunity, quantity, price, stoploss, takeprofit, 0)
+}
+
+// placeOpportunityOrder claims an opportunity and places its order, so that of two executions or an
+// execution racing the auto trader only one reaches the exchange. The opportunity is returned taken
+// once the exchange accepted the order, even while its executionReport is still on its way.
+func placeOpportunityOrder(opportunity models.Opportunity, quantity, price, stoploss, takeprofit float64, autorepeat int) (models.Opportunity, error) {
+	claim := utils.SqlDB.Model(&models.Opportunity{}).
+		Where("id = ? AND COALESCE(status, '') NOT IN (?, ?)", opportunity.ID, models.OpportunityPending, models.OpportunityTaken).
+		Updates(map[string]interface{}{"status": models.OpportunityPending})
+	if claim.Error != nil {
+		return opportunity, claim.Error
+	}
+
+	if claim.RowsAffected == 0 {
+		return opportunity, fmt.Errorf("Opportunity %v is already being executed or was taken", opportunity.ID)
+	}
+
+	var orderID uint64
+	switch opportunity.Exchange {
+	default:
+		orderID = binanceOrderCreate(opportunity.Pair, opportunity.Action, strconv.FormatFloat(price, 'f', -1, 64), strconv.FormatFloat(quantity, 'f', -1, 64), stoploss, takeprofit, autorepeat, 0, opportunity.ID)
+	case "crex24":
+		orderID = crex24OrderCreate(opportunity.Pair, opportunity.Action, price, quantity, stoploss, takeprofit, autorepeat, 0, opportunity.ID)
+	}
+
+	if orderID == 0 {
+		// released for another attempt, the exchange having refused or never received the order
+		if err := utils.SqlDB.Model(&models.Opportunity{}).Where("id = ? AND status = ?", opportunity.ID, models.OpportunityPending).
+			Updates(map[string]interface{}{"status": opportunity.Status}).Error; err != nil {
+			log.Println(err.Error())
+		}
+		return opportunity, fmt.Errorf("Order for opportunity %v was not placed", opportunity.ID)
+	}
+
+	opportunity.Status = models.OpportunityTaken
+	opportunity.OrderID = orderID
+	return opportunity, nil
+}
+
+// markOpportunityTaken records that orderID was placed for the opportunity.
+func markOpportunityTaken(opportunityID, orderID uint64) {
+	if utils.SqlDB == nil {
+		return
+	}
+
+	if err := utils.SqlDB.Model(&models.Opportunity{}).Where("id = ?", opportunityID).
+		Updates(map[string]interface{}{"status": models.OpportunityTaken, "orderid": orderID}).Error; err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func restHandlerExecuteOpportunity(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	opportunityID, err := strconv.ParseUint(query.Get("id"), 10, 64)
+	if err != nil {
+		http.Error(httpRes, "Invalid id parameter", http.StatusBadRequest)
+		return
+	}
+
+	quantity, err := strconv.ParseFloat(query.Get("quantity"), 64)
+	if err != nil {
+		http.Error(httpRes, "Invalid quantity parameter", http.StatusBadRequest)
+		return
+	}
+
+	price, err := strconv.ParseFloat(query.Get("price"), 64)
+	if err != nil {
+		price = 0
+	}
+
+	opportunity, err := executeOpportunity(opportunityID, quantity, price)
+	if err != nil {
+		http.Error(httpRes, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(opportunity)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
 }
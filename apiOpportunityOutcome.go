@@ -0,0 +1,269 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	klineIntervalDurations = map[string]time.Duration{
+		"1m": time.Minute, "3m": 3 * time.Minute, "5m": 5 * time.Minute, "15m": 15 * time.Minute,
+		"30m": 30 * time.Minute, "1h": time.Hour, "2h": 2 * time.Hour, "4h": 4 * time.Hour,
+		"6h": 6 * time.Hour, "8h": 8 * time.Hour, "12h": 12 * time.Hour, "1d": 24 * time.Hour,
+		"3d": 3 * 24 * time.Hour, "1w": 7 * 24 * time.Hour, "1M": 30 * 24 * time.Hour,
+	}
+)
+
+type opportunityStatsType struct {
+	Pair             string  `gorm:"column:pair"`
+	Timeframe        string  `gorm:"column:timeframe"`
+	Action           string  `gorm:"column:action"`
+	Trend            string  `gorm:"column:trend"`
+	Total            int     `gorm:"column:total"`
+	TPHit            int     `gorm:"column:tphit"`
+	SLHit            int     `gorm:"column:slhit"`
+	Expired          int     `gorm:"column:expired"`
+	WinRate          float64 `gorm:"-"`
+	AvgTimeToOutcome float64 `gorm:"column:avgtimetooutcome"`
+	AvgMaxFavourable float64 `gorm:"column:avgmaxfavourable"`
+	AvgMaxAdverse    float64 `gorm:"column:avgmaxadverse"`
+}
+
+// GoEvaluateOpportunityOutcomes periodically walks the klines that followed every open
+// opportunity and records whether its takeprofit or stoploss was hit first, or it expired.
+// Opportunities of the same market and timeframe share one klines request a pass.
+func GoEvaluateOpportunityOutcomes() {
+	ticker := time.NewTicker(time.Minute * 5)
+	defer ticker.Stop()
+
+	for ; true; <-ticker.C {
+		if utils.SqlDB == nil {
+			continue
+		}
+
+		var pendingList []models.Opportunity
+		if err := utils.SqlDB.Where("outcome = '' OR outcome IS NULL").Order("createdate").Find(&pendingList).Error; err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		expiryCandles := utils.Config.Opportunity.ExpiryCandles
+		if expiryCandles <= 0 || expiryCandles > 1000 {
+			expiryCandles = 1000
+		}
+
+		// pendingList is ordered by createdate, so each group starts with its oldest opportunity
+		var groupKeys []string
+		groups := make(map[string][]models.Opportunity)
+		for _, opportunity := range pendingList {
+			if klineIntervalDurations[opportunity.Timeframe] == 0 || opportunity.Price == 0 || opportunity.Exchange != "binance" {
+				continue
+			}
+
+			groupKey := fmt.Sprintf("%s-%s-%s", opportunity.Pair, opportunity.Exchange, opportunity.Timeframe)
+			if _, ok := groups[groupKey]; !ok {
+				groupKeys = append(groupKeys, groupKey)
+			}
+			groups[groupKey] = append(groups[groupKey], opportunity)
+		}
+
+		loc, _ := time.LoadLocation("CET")
+		for _, groupKey := range groupKeys {
+			group := groups[groupKey]
+			timeframe := group[0].Timeframe
+			duration := klineIntervalDurations[timeframe]
+
+			startTime := group[0].Createdate.In(loc).Format(time.DateTime)
+			klines := binanceKlines([]string{timeframe}, group[0].Pair, startTime, "", 1000)[timeframe]
+			if len(klines) == 0 {
+				continue
+			}
+
+			// the klines reach the present unless the request was cut at its limit
+			var coveredUntil time.Time
+			if len(klines) < 1000 {
+				coveredUntil = time.Now()
+			} else {
+				coveredUntil = klines[len(klines)-1].Timestamp.Add(duration)
+			}
+
+			now := time.Now()
+			for _, opportunity := range group {
+				windowEnd := opportunity.Createdate.Add(duration * time.Duration(expiryCandles))
+				if windowEnd.After(now) {
+					windowEnd = now
+				}
+				if coveredUntil.Before(windowEnd) {
+					continue
+				}
+
+				evaluateOpportunityOutcome(&opportunity, klines, duration, expiryCandles, now)
+				saveOpportunityOutcome(opportunity)
+			}
+
+			time.Sleep(time.Millisecond * 250)
+		}
+	}
+}
+
+// saveOpportunityOutcome writes only the outcome columns, so the status and order an execution
+// set while the klines were being fetched are kept.
+func saveOpportunityOutcome(opportunity models.Opportunity) {
+	if err := utils.SqlDB.Model(&models.Opportunity{}).Where("id = ?", opportunity.ID).
+		Select("Outcome", "OutcomePrice", "OutcomeDate", "TimeToOutcome", "MaxFavourable", "MaxAdverse").
+		Updates(&models.Opportunity{
+			Outcome: opportunity.Outcome, OutcomePrice: opportunity.OutcomePrice, OutcomeDate: opportunity.OutcomeDate,
+			TimeToOutcome: opportunity.TimeToOutcome, MaxFavourable: opportunity.MaxFavourable, MaxAdverse: opportunity.MaxAdverse,
+		}).Error; err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// evaluateOpportunityOutcome updates the excursions of opportunity from the klines that followed it
+// and sets its outcome once the stoploss or takeprofit is crossed or expiryCandles have passed.
+// A candle crossing both levels counts as a stoploss hit, since the order within it is unknown.
+func evaluateOpportunityOutcome(opportunity *models.Opportunity, klines []TypeKline, duration time.Duration, expiryCandles int, now time.Time) {
+	if opportunity.Outcome != "" || opportunity.Price == 0 {
+		return
+	}
+
+	price := opportunity.Price
+	var lastClose float64
+	for _, kline := range klines {
+		if kline.Timestamp.Before(opportunity.Createdate) {
+			continue
+		}
+		lastClose = kline.Close
+
+		var favourable, adverse float64
+		var slHit, tpHit bool
+		switch opportunity.Action {
+		case "BUY":
+			favourable = (kline.High - price) / price * 100
+			adverse = (price - kline.Low) / price * 100
+			slHit = opportunity.Stoploss > 0 && kline.Low <= opportunity.Stoploss
+			tpHit = opportunity.Takeprofit > 0 && kline.High >= opportunity.Takeprofit
+		case "SELL":
+			favourable = (price - kline.Low) / price * 100
+			adverse = (kline.High - price) / price * 100
+			slHit = opportunity.Stoploss > 0 && kline.High >= opportunity.Stoploss
+			tpHit = opportunity.Takeprofit > 0 && kline.Low <= opportunity.Takeprofit
+		default:
+			return
+		}
+
+		if favourable > opportunity.MaxFavourable {
+			opportunity.MaxFavourable = utils.TruncateFloat(favourable, 3)
+		}
+		if adverse > opportunity.MaxAdverse {
+			opportunity.MaxAdverse = utils.TruncateFloat(adverse, 3)
+		}
+
+		switch {
+		case slHit:
+			opportunity.Outcome = models.OpportunitySLHit
+			opportunity.OutcomePrice = opportunity.Stoploss
+		case tpHit:
+			opportunity.Outcome = models.OpportunityTPHit
+			opportunity.OutcomePrice = opportunity.Takeprofit
+		default:
+			continue
+		}
+
+		opportunity.OutcomeDate = kline.Timestamp.Add(duration)
+		if opportunity.OutcomeDate.After(now) {
+			opportunity.OutcomeDate = now
+		}
+		opportunity.TimeToOutcome = int64(opportunity.OutcomeDate.Sub(opportunity.Createdate).Seconds())
+		return
+	}
+
+	expiry := opportunity.Createdate.Add(duration * time.Duration(expiryCandles))
+	if now.After(expiry) {
+		opportunity.Outcome = models.OpportunityExpired
+		opportunity.OutcomePrice = lastClose
+		opportunity.OutcomeDate = expiry
+		opportunity.TimeToOutcome = int64(expiry.Sub(opportunity.Createdate).Seconds())
+	}
+}
+
+func restHandlerOpportunityStats(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	pair := query.Get("pair")
+	action := query.Get("action")
+	trend := query.Get("trend")
+	exchange := query.Get("exchange")
+	timeframe := query.Get("timeframe")
+
+	starttime := query.Get("starttime")
+	endtime := query.Get("endtime")
+
+	if utils.SqlDB == nil {
+		http.Error(httpRes, "Database not configured", http.StatusInternalServerError)
+		return
+	}
+
+	searchText := " outcome <> '' "
+	var searchParams []interface{}
+
+	for column, value := range map[string]string{
+		"pair": pair, "action": action, "trend": trend, "exchange": exchange, "timeframe": timeframe,
+	} {
+		if value != "" {
+			searchText += " AND " + column + " like ? "
+			searchParams = append(searchParams, value)
+		}
+	}
+
+	if starttime != "" {
+		searchText += " AND createdate >= ?::timestamp "
+		searchParams = append(searchParams, starttime)
+	}
+
+	if endtime != "" {
+		searchText += " AND createdate <= ?::timestamp "
+		searchParams = append(searchParams, endtime)
+	}
+
+	var statsList []opportunityStatsType
+	if err := utils.SqlDB.Model(&models.Opportunity{}).
+		Select(`pair, timeframe, action, trend, count(*) as total,
+			count(*) filter (where outcome = ?) as tphit,
+			count(*) filter (where outcome = ?) as slhit,
+			count(*) filter (where outcome = ?) as expired,
+			avg(timetooutcome) as avgtimetooutcome,
+			avg(maxfavourable) as avgmaxfavourable,
+			avg(maxadverse) as avgmaxadverse`,
+			models.OpportunityTPHit, models.OpportunitySLHit, models.OpportunityExpired).
+		Where(searchText, searchParams...).
+		Group("pair, timeframe, action, trend").
+		Order("pair, timeframe, action, trend").
+		Scan(&statsList).Error; err != nil {
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for index, stats := range statsList {
+		if decided := stats.TPHit + stats.SLHit; decided > 0 {
+			statsList[index].WinRate = utils.TruncateFloat(float64(stats.TPHit)/float64(decided)*100, 2)
+		}
+		statsList[index].AvgTimeToOutcome = utils.TruncateFloat(stats.AvgTimeToOutcome, 0)
+		statsList[index].AvgMaxFavourable = utils.TruncateFloat(stats.AvgMaxFavourable, 3)
+		statsList[index].AvgMaxAdverse = utils.TruncateFloat(stats.AvgMaxAdverse, 3)
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(statsList)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"backpocket/utils"
+	"testing"
+)
+
+// longSummary is a timeframe on which every long rule of checkIfLong passes at price 100.
+func longSummary(timeframe string) (summary utils.Summary) {
+	summary.Timeframe = timeframe
+	summary.RSI = 30
+	summary.Trend = "Bearish"
+	summary.RetracementLevels = map[string]float64{"0.236": 110, "0.786": 110}
+	summary.SMA50.Support = 90
+	summary.Candle = utils.Candle{Low: 95}
+	summary.BollingerBands = map[string]float64{"lower": 96}
+	return
+}
+
+func TestOpportunityDefaults(t *testing.T) {
+	saved := utils.Config
+	defer func() { utils.Config = saved }()
+
+	// no dbconfig, so Init reads the defaults without connecting to a database
+	utils.Init([]byte("encryption_keys:\n  public: public.pem\n  private: private.pem\n"))
+
+	if utils.Config.Opportunity.Threshold != 1.0 {
+		t.Errorf("default threshold is %v, want 1.0", utils.Config.Opportunity.Threshold)
+	}
+
+	for condition, weight := range map[string]float64{
+		"rsi": 1, "fib": 1, "trend": 1, "bollinger": 1, "support": 1, "resistance": 1,
+		"imbalance": 0, "wall": 0, "volumeprofile": 0,
+	} {
+		if utils.Config.Opportunity.Weights[condition] != weight {
+			t.Errorf("default weight of %s is %v, want %v", condition, utils.Config.Opportunity.Weights[condition], weight)
+		}
+	}
+}
+
+func TestScoreConditions(t *testing.T) {
+	saved := utils.Config.Opportunity.Weights
+	defer func() { utils.Config.Opportunity.Weights = saved }()
+
+	for _, test := range []struct {
+		name    string
+		weights map[string]float64
+		checks  map[string]bool
+		score   float64
+	}{
+		{"all passed", map[string]float64{"rsi": 1, "trend": 1}, map[string]bool{"rsi": true, "trend": true}, 1},
+		{"half the weight passed", map[string]float64{"rsi": 1, "trend": 1}, map[string]bool{"rsi": true, "trend": false}, 0.5},
+		{"weighted", map[string]float64{"rsi": 3, "trend": 1}, map[string]bool{"rsi": true, "trend": false}, 0.75},
+		{"unweighted condition counts as 1", map[string]float64{"rsi": 1}, map[string]bool{"rsi": true, "fib": false}, 0.5},
+		{"zero weight failing is left out", map[string]float64{"rsi": 1, "wall": 0}, map[string]bool{"rsi": true, "wall": false}, 1},
+		{"zero weight passing adds nothing", map[string]float64{"rsi": 1, "wall": 0}, map[string]bool{"rsi": false, "wall": true}, 0},
+		{"no weight at all", map[string]float64{"rsi": 0}, map[string]bool{"rsi": true}, 0},
+	} {
+		utils.Config.Opportunity.Weights = test.weights
+		score, conditions := scoreConditions(test.checks, nil)
+		if score != test.score {
+			t.Errorf("%s: score %v, want %v", test.name, score, test.score)
+		}
+		for name, passed := range test.checks {
+			if conditions[name].Passed != passed || (passed && conditions[name].Score != conditions[name].Weight) {
+				t.Errorf("%s: condition %s is %+v", test.name, name, conditions[name])
+			}
+		}
+	}
+}
+
+func TestCheckIfLongZeroWeightConditions(t *testing.T) {
+	saved := utils.Config.Opportunity
+	defer func() { utils.Config.Opportunity = saved }()
+
+	utils.Config.Opportunity.Threshold = 1.0
+	lower, middle, upper := longSummary("15m"), longSummary("1h"), longSummary("4h")
+
+	for _, test := range []struct {
+		name    string
+		weights map[string]float64
+		score   float64
+	}{
+		{"orderbook and volume profile off", map[string]float64{
+			"rsi": 1, "fib": 1, "trend": 1, "bollinger": 1, "support": 1,
+			"imbalance": 0, "wall": 0, "volumeprofile": 0,
+		}, 1},
+		{"volume profile weighted", map[string]float64{
+			"rsi": 1, "fib": 1, "trend": 1, "bollinger": 1, "support": 1,
+			"imbalance": 0, "wall": 0, "volumeprofile": 5,
+		}, 0.5},
+		{"orderbook weighted", map[string]float64{
+			"rsi": 1, "fib": 1, "trend": 1, "bollinger": 1, "support": 1,
+			"imbalance": 2.5, "wall": 2.5, "volumeprofile": 0,
+		}, 0.5},
+	} {
+		utils.Config.Opportunity.Weights = test.weights
+
+		// without orderbook analytics or a volume profile the zero weight rules all fail
+		score, conditions := checkIfLong(100, orderbookAnalyticsType{}, lower, middle, upper)
+		if score != test.score {
+			t.Errorf("%s: score %v, want %v: %+v", test.name, score, test.score, conditions)
+		}
+		for _, name := range []string{"imbalance", "wall", "volumeprofile"} {
+			if conditions[name].Passed {
+				t.Errorf("%s: %s passed without data", test.name, name)
+			}
+		}
+
+		if passed := score >= utils.Config.Opportunity.Threshold; passed != (test.score == 1) {
+			t.Errorf("%s: score %v against threshold 1.0 passed %v", test.name, score, passed)
+		}
+	}
+}
+
+func TestCheckIfShortZeroWeightConditions(t *testing.T) {
+	saved := utils.Config.Opportunity.Weights
+	defer func() { utils.Config.Opportunity.Weights = saved }()
+
+	utils.Config.Opportunity.Weights = map[string]float64{
+		"rsi": 1, "fib": 1, "trend": 1, "bollinger": 1, "resistance": 1,
+		"imbalance": 0, "wall": 0, "volumeprofile": 0,
+	}
+
+	// a long setup fails every short rule, and the zero weight ones cannot lift the score
+	lower, middle, upper := longSummary("15m"), longSummary("1h"), longSummary("4h")
+	middle.SMA50.Resistance = 120
+	score, conditions := checkIfShort(100, orderbookAnalyticsType{}, lower, middle, upper)
+	if score != 0 {
+		t.Errorf("short score %v, want 0: %+v", score, conditions)
+	}
+	if _, found := conditions["volumeprofile"]; !found {
+		t.Error("volumeprofile is not a condition of the short check")
+	}
+}
@@ -5,48 +5,27 @@ import (
 	"backpocket/utils"
 	"log"
 	"net/http"
-	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 func wsHandlerOrderHistory(httpRes http.ResponseWriter, httpReq *http.Request) {
 	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
 
-		ticker := time.NewTicker(pingPeriod)
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
-		defer ticker.Stop()
-		defer wsConn.Close()
+		client := wsHubOrderHistory.register(wsConn, httpReq, nil)
+		defer client.close()
 
 		for {
 			msg := searchOrderMsgType{}
-			select {
-			case <-ticker.C:
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					println("websocket.PingMessage: ", err.Error())
-					continue
-				}
-
-			default:
-				if err := wsConn.ReadJSON(&msg); err != nil {
-					return
-				}
-
-				if msg.Pair == "" {
-					continue
-				}
-
-				if err := wsConn.WriteJSON(searchOrderSQL(msg)); err != nil {
-					log.Println(err.Error())
-					continue
-				}
-				//check if msg pair is valid and we can create a new set of orders for it
-				//
+			if err := wsConn.ReadJSON(&msg); err != nil {
+				return
+			}
 
+			if msg.Pair == "" {
+				continue
 			}
+
+			client.reply(msg.ID, "search", searchOrderSQL(msg), nil)
+			//check if msg pair is valid and we can create a new set of orders for it
+			//
 		}
 	}
 }
@@ -55,6 +34,9 @@ type searchOrderMsgType struct {
 	Pair, Status, Side,
 	Start, Stop, OrderID,
 	RefID, Exchange string
+
+	// ID is echoed in the response to the search
+	ID string
 }
 
 func searchOrderSQL(msg searchOrderMsgType) (filteredOrderList []models.Order) {
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	OrderbookWallAppeared = "appeared"
+	OrderbookWallRemoved  = "removed"
+	OrderbookWallFilled   = "filled"
+
+	orderbookWallEventsLimit = 50
+)
+
+// orderbookBandType is the resting liquidity within Band percent of the mid price, Imbalance running
+// from -1 when it is all asks to 1 when it is all bids.
+type orderbookBandType struct {
+	Band                 float64
+	BidsQuote, AsksQuote float64
+	Imbalance            float64
+}
+
+type orderbookWallType struct {
+	Side                      string
+	Price, Quantity, QuoteQty float64
+	// Multiple is the size of the wall over the median level of its side
+	Multiple            float64
+	FirstSeen, LastSeen time.Time
+}
+
+type orderbookWallEventType struct {
+	Event     string
+	Wall      orderbookWallType
+	Lifetime  float64
+	Spoof     bool
+	Timestamp time.Time
+}
+
+type orderbookSpreadType struct {
+	Timestamp time.Time
+	SpreadBps float64
+}
+
+type orderbookAnalyticsType struct {
+	Pair, Exchange string
+	Timestamp      time.Time
+
+	BestBid, BestAsk, MidPrice,
+	Spread, SpreadBps float64
+	SpreadAverageBps, SpreadMinBps,
+	SpreadMaxBps float64
+
+	Bands  []orderbookBandType
+	Walls  []orderbookWallType
+	Events []orderbookWallEventType
+	// SpoofCount is the number of recent walls pulled before price reached them
+	SpoofCount int
+}
+
+// orderbookTrackerType carries the walls and spreads of a pair from one orderbook update to the next.
+type orderbookTrackerType struct {
+	Walls   map[string]orderbookWallType
+	Events  []orderbookWallEventType
+	Spreads []orderbookSpreadType
+}
+
+var (
+	orderbookAnalyticsList    []orderbookAnalyticsType
+	orderbookAnalyticsListMap = make(map[string]int)
+	orderbookTrackers         = make(map[string]*orderbookTrackerType)
+
+	orderbookAnalyticsListMutex = sync.RWMutex{}
+	orderbookTrackersMutex      = sync.RWMutex{}
+
+	wsBroadcastOrderbookAnalytics = make(chan orderbookAnalyticsType, 10240)
+
+	chanOrderbookAnalytics = make(chan orderbooks, 10240)
+)
+
+func getOrderbookAnalytics(pair, exchange string) (analytics orderbookAnalyticsType) {
+	orderbookAnalyticsListMutex.RLock()
+	defer orderbookAnalyticsListMutex.RUnlock()
+
+	if key := orderbookAnalyticsListMap[fmt.Sprintf("%s-%s", pair, strings.ToLower(exchange))]; key > 0 && len(orderbookAnalyticsList) >= key {
+		analytics = orderbookAnalyticsList[key-1]
+	}
+	return
+}
+
+func updateOrderbookAnalytics(analytics orderbookAnalyticsType) {
+	if analytics.Pair == "" {
+		return
+	}
+
+	pairexchange := fmt.Sprintf("%s-%s", analytics.Pair, strings.ToLower(analytics.Exchange))
+	orderbookAnalyticsListMutex.Lock()
+	if key := orderbookAnalyticsListMap[pairexchange]; key > 0 {
+		orderbookAnalyticsList[key-1] = analytics
+	} else {
+		orderbookAnalyticsList = append(orderbookAnalyticsList, analytics)
+		orderbookAnalyticsListMap[pairexchange] = len(orderbookAnalyticsList)
+	}
+	orderbookAnalyticsListMutex.Unlock()
+}
+
+// orderbookBands sums the bids and asks within each band of the mid price.
+func orderbookBands(orderbook orderbooks, midPrice float64, bands []float64) (bandList []orderbookBandType) {
+	for _, band := range bands {
+		bandType := orderbookBandType{Band: band}
+		for _, bid := range orderbook.Bids {
+			if bid.Price < midPrice*(1-band/100) {
+				break
+			}
+			bandType.BidsQuote += bid.Price * bid.Quantity
+		}
+		for _, ask := range orderbook.Asks {
+			if ask.Price > midPrice*(1+band/100) {
+				break
+			}
+			bandType.AsksQuote += ask.Price * ask.Quantity
+		}
+
+		if total := bandType.BidsQuote + bandType.AsksQuote; total > 0 {
+			bandType.Imbalance = utils.TruncateFloat((bandType.BidsQuote-bandType.AsksQuote)/total, 3)
+		}
+		bandType.BidsQuote = utils.TruncateFloat(bandType.BidsQuote, 8)
+		bandType.AsksQuote = utils.TruncateFloat(bandType.AsksQuote, 8)
+		bandList = append(bandList, bandType)
+	}
+	return
+}
+
+// orderbookWalls returns the levels within maxBand of the mid price that rest at least multiple times
+// the median level of their side.
+func orderbookWalls(side string, levels []bidAskStruct, midPrice, maxBand, multiple float64) (walls []orderbookWallType) {
+	var inBand []bidAskStruct
+	for _, level := range levels {
+		if math.Abs(level.Price-midPrice)/midPrice*100 > maxBand {
+			break
+		}
+		inBand = append(inBand, level)
+	}
+
+	if len(inBand) < 5 || multiple <= 0 {
+		return
+	}
+
+	quantities := make([]float64, len(inBand))
+	for id, level := range inBand {
+		quantities[id] = level.Quantity
+	}
+	sort.Float64s(quantities)
+
+	median := quantities[len(quantities)/2]
+	if median == 0 {
+		return
+	}
+
+	for _, level := range inBand {
+		if level.Quantity >= median*multiple {
+			walls = append(walls, orderbookWallType{Side: side, Price: level.Price, Quantity: level.Quantity,
+				QuoteQty: level.Price * level.Quantity, Multiple: utils.TruncateFloat(level.Quantity/median, 2)})
+		}
+	}
+	return
+}
+
+// trackWalls matches the current walls against the previous update, recording walls that appeared
+// and walls that went. A wall that went before price reached it is removed rather than filled, and
+// flagged as a spoof when it rested for less than the spoof window.
+func (tracker *orderbookTrackerType) trackWalls(walls []orderbookWallType, bestBid, bestAsk float64, now time.Time) []orderbookWallType {
+	current := make(map[string]orderbookWallType)
+	for _, wall := range walls {
+		key := fmt.Sprintf("%s-%v", wall.Side, wall.Price)
+		if previous, found := tracker.Walls[key]; found {
+			wall.FirstSeen = previous.FirstSeen
+		} else {
+			wall.FirstSeen = now
+			tracker.Events = append(tracker.Events, orderbookWallEventType{Event: OrderbookWallAppeared, Wall: wall, Timestamp: now})
+		}
+		wall.LastSeen = now
+		current[key] = wall
+	}
+
+	for key, wall := range tracker.Walls {
+		if _, found := current[key]; found {
+			continue
+		}
+
+		event := orderbookWallEventType{Event: OrderbookWallRemoved, Wall: wall, Timestamp: now,
+			Lifetime: utils.TruncateFloat(now.Sub(wall.FirstSeen).Seconds(), 3)}
+		if (wall.Side == "bid" && bestBid <= wall.Price) || (wall.Side == "ask" && bestAsk >= wall.Price) {
+			event.Event = OrderbookWallFilled
+		} else {
+			event.Spoof = now.Sub(wall.FirstSeen) < utils.Config.OrderbookAnalytics.SpoofWindow
+		}
+		tracker.Events = append(tracker.Events, event)
+	}
+
+	if len(tracker.Events) > orderbookWallEventsLimit {
+		tracker.Events = tracker.Events[len(tracker.Events)-orderbookWallEventsLimit:]
+	}
+	tracker.Walls = current
+
+	currentWalls := make([]orderbookWallType, 0, len(current))
+	for _, wall := range current {
+		currentWalls = append(currentWalls, wall)
+	}
+	sort.Slice(currentWalls, func(i, j int) bool { return currentWalls[i].QuoteQty > currentWalls[j].QuoteQty })
+	return currentWalls
+}
+
+// trackSpread records the spread and drops the samples older than the spread window.
+func (tracker *orderbookTrackerType) trackSpread(spreadBps float64, now time.Time) (average, min, max float64) {
+	tracker.Spreads = append(tracker.Spreads, orderbookSpreadType{Timestamp: now, SpreadBps: spreadBps})
+	for len(tracker.Spreads) > 0 && now.Sub(tracker.Spreads[0].Timestamp) > utils.Config.OrderbookAnalytics.SpreadWindow {
+		tracker.Spreads = tracker.Spreads[1:]
+	}
+
+	min = math.MaxFloat64
+	for _, spread := range tracker.Spreads {
+		average += spread.SpreadBps
+		min = math.Min(min, spread.SpreadBps)
+		max = math.Max(max, spread.SpreadBps)
+	}
+	average /= float64(len(tracker.Spreads))
+	return utils.TruncateFloat(average, 3), min, max
+}
+
+// analyseOrderbook computes the imbalance bands, walls and spread of an orderbook update.
+func analyseOrderbook(orderbook orderbooks, now time.Time) (analytics orderbookAnalyticsType) {
+	if orderbook.Pair == "" || len(orderbook.Bids) == 0 || len(orderbook.Asks) == 0 {
+		return
+	}
+
+	analytics.Pair = orderbook.Pair
+	analytics.Exchange = orderbook.Exchange
+	analytics.Timestamp = now
+	analytics.BestBid = orderbook.Bids[0].Price
+	analytics.BestAsk = orderbook.Asks[0].Price
+	analytics.MidPrice = (analytics.BestBid + analytics.BestAsk) / 2
+	analytics.Spread = utils.TruncateFloat(analytics.BestAsk-analytics.BestBid, 8)
+	analytics.SpreadBps = utils.TruncateFloat(analytics.Spread/analytics.MidPrice*10000, 3)
+
+	bands := utils.Config.OrderbookAnalytics.Bands
+	analytics.Bands = orderbookBands(orderbook, analytics.MidPrice, bands)
+
+	var maxBand float64
+	if len(bands) > 0 {
+		maxBand = bands[len(bands)-1]
+	}
+	multiple := utils.Config.OrderbookAnalytics.WallMultiple
+	walls := append(orderbookWalls("bid", orderbook.Bids, analytics.MidPrice, maxBand, multiple),
+		orderbookWalls("ask", orderbook.Asks, analytics.MidPrice, maxBand, multiple)...)
+
+	pairexchange := fmt.Sprintf("%s-%s", orderbook.Pair, strings.ToLower(orderbook.Exchange))
+	orderbookTrackersMutex.Lock()
+	tracker, found := orderbookTrackers[pairexchange]
+	if !found {
+		tracker = &orderbookTrackerType{Walls: make(map[string]orderbookWallType)}
+		orderbookTrackers[pairexchange] = tracker
+	}
+
+	analytics.Walls = tracker.trackWalls(walls, analytics.BestBid, analytics.BestAsk, now)
+	analytics.Events = append([]orderbookWallEventType{}, tracker.Events...)
+	analytics.SpreadAverageBps, analytics.SpreadMinBps, analytics.SpreadMaxBps = tracker.trackSpread(analytics.SpreadBps, now)
+	orderbookTrackersMutex.Unlock()
+
+	for _, event := range analytics.Events {
+		if event.Spoof {
+			analytics.SpoofCount++
+		}
+	}
+	return
+}
+
+// orderbookConditionDetails turns the orderbook analytics of a pair into the imbalance and wall rules
+// of the long or the short check. The imbalance rule wants the bids, or the asks when short, to
+// outweigh the other side by the imbalance threshold, and the wall rule wants more resting wall
+// liquidity behind the trade than in front of it.
+func orderbookConditionDetails(analytics orderbookAnalyticsType, long bool) (imbalance, wall []conditionDetail) {
+	band := utils.Config.OrderbookAnalytics.ImbalanceBand
+	threshold := utils.Config.OrderbookAnalytics.ImbalanceThreshold
+
+	var bandImbalance float64
+	for _, bandType := range analytics.Bands {
+		if bandType.Band == band {
+			bandImbalance = bandType.Imbalance
+		}
+	}
+
+	var bidWalls, askWalls float64
+	for _, orderbookWall := range analytics.Walls {
+		switch orderbookWall.Side {
+		case "bid":
+			bidWalls += orderbookWall.QuoteQty
+		case "ask":
+			askWalls += orderbookWall.QuoteQty
+		}
+	}
+
+	timeframe := fmt.Sprintf("orderbook %v%%", band)
+	if long {
+		imbalance = []conditionDetail{{Timeframe: timeframe, Input: bandImbalance, Operator: ">=", Threshold: threshold,
+			Passed: analytics.Pair != "" && bandImbalance >= threshold}}
+		wall = []conditionDetail{{Timeframe: "orderbook", Input: bidWalls, Operator: ">", Threshold: askWalls,
+			Passed: bidWalls > askWalls}}
+		return
+	}
+
+	imbalance = []conditionDetail{{Timeframe: timeframe, Input: bandImbalance, Operator: "<=", Threshold: -threshold,
+		Passed: analytics.Pair != "" && bandImbalance <= -threshold}}
+	wall = []conditionDetail{{Timeframe: "orderbook", Input: askWalls, Operator: ">", Threshold: bidWalls,
+		Passed: askWalls > bidWalls}}
+	return
+}
+
+// apiOrderbookAnalytics analyses every orderbook update published by the exchange streams.
+func apiOrderbookAnalytics() {
+	for orderbook := range chanOrderbookAnalytics {
+		orderbookMutex.RLock()
+		analytics := analyseOrderbook(orderbook, time.Now())
+		orderbookMutex.RUnlock()
+
+		if analytics.Pair == "" {
+			continue
+		}
+
+		updateOrderbookAnalytics(analytics)
+		select {
+		case wsBroadcastOrderbookAnalytics <- analytics:
+		default:
+		}
+	}
+}
+
+func restHandlerOrderbookAnalytics(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	pair := strings.ToUpper(query.Get("pair"))
+	if pair == "" {
+		http.Error(httpRes, "Missing pair parameter", http.StatusBadRequest)
+		return
+	}
+
+	exchange := strings.ToLower(query.Get("exchange"))
+	if exchange == "" {
+		exchange = "binance"
+	}
+
+	analytics := getOrderbookAnalytics(pair, exchange)
+	if analytics.Pair == "" {
+		http.Error(httpRes, fmt.Sprintf("No orderbook analytics for %s on %s", pair, exchange), http.StatusNotFound)
+		return
+	}
+
+	response := struct {
+		orderbookAnalyticsType
+		Spreads []orderbookSpreadType
+	}{orderbookAnalyticsType: analytics}
+
+	orderbookTrackersMutex.RLock()
+	if tracker, found := orderbookTrackers[fmt.Sprintf("%s-%s", pair, exchange)]; found {
+		response.Spreads = append(response.Spreads, tracker.Spreads...)
+	}
+	orderbookTrackersMutex.RUnlock()
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
+
+func wsHandlerOrderbookAnalytics(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
+
+		client := wsHubOrderbookAnalytics.register(wsConn, httpReq, sendOrderbookAnalytics)
+		defer client.close()
+
+		for {
+			var msg wsSubscriptionMsgType
+			if err := wsConn.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			client.handle(msg)
+		}
+	}
+}
+
+// events names what an analytics update carries for subscriptions: "analytics", the wall events
+// it holds and "spoof" when one of them was a pulled wall.
+func (analytics orderbookAnalyticsType) events() (events []string) {
+	events = append(events, "analytics")
+	for _, event := range analytics.Events {
+		events = append(events, event.Event)
+		if event.Spoof {
+			events = append(events, "spoof")
+		}
+	}
+	return
+}
+
+// sendOrderbookAnalytics queues for a client the analytics subscriptions match.
+func sendOrderbookAnalytics(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	orderbookAnalyticsListMutex.RLock()
+	defer orderbookAnalyticsListMutex.RUnlock()
+
+	for _, analytics := range orderbookAnalyticsList {
+		if subscriptions.matches(analytics.Pair, analytics.Exchange, "", analytics.events()...) {
+			client.send("orderbookanalytics", fmt.Sprintf("%s-%s", analytics.Pair, strings.ToLower(analytics.Exchange)), analytics)
+		}
+	}
+}
+
+func wsHandlerOrderbookAnalyticsBroadcast() {
+	go func() {
+		for analytics := range wsBroadcastOrderbookAnalytics {
+			events := analytics.events()
+			wsHubOrderbookAnalytics.broadcast("orderbookanalytics", fmt.Sprintf("%s-%s", analytics.Pair, strings.ToLower(analytics.Exchange)), analytics,
+				func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+					return analytics, subscriptions.matches(analytics.Pair, analytics.Exchange, "", events...)
+				})
+		}
+	}()
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"backpocket/utils"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
@@ -17,9 +19,7 @@ var (
 	orderbookMutex        = sync.RWMutex{}
 	orderbookListMutex    = sync.RWMutex{}
 	orderbookListMapMutex = sync.RWMutex{}
-	wsConnOrderbooksMutex = sync.RWMutex{}
 
-	wsConnOrderbooks     = make(map[*websocket.Conn]bool)
 	wsBroadcastOrderBook = make(chan interface{}, 10240)
 )
 
@@ -67,28 +67,137 @@ func getOrderbook(orderbookPair, orderbookExchange string) (orderbook orderbooks
 	return orderbook
 }
 
-func wsHandlerOrderbooks(httpRes http.ResponseWriter, httpReq *http.Request) {
-	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
+// orderbookLevels sorts price levels best first and accumulates their totals, keeping at most depth
+// levels when depth is above zero.
+func orderbookLevels(levels map[float64]float64, descending bool, depth int) (sideLevels []bidAskStruct, baseTotal, quoteTotal float64) {
+	prices := make([]float64, 0, len(levels))
+	for price := range levels {
+		prices = append(prices, price)
+	}
 
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
+	sort.Float64s(prices)
+	if descending {
+		sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+	}
 
-		var unlockedOrderbookList []orderbooks
-		orderbookListMutex.RLock()
-		for _, orderbook := range orderbookList {
-			unlockedOrderbookList = append(unlockedOrderbookList, orderbook)
+	if depth > 0 && len(prices) > depth {
+		prices = prices[:depth]
+	}
+
+	for _, price := range prices {
+		quantity := levels[price]
+		quoteQty := quantity * price
+		baseTotal += quantity
+		quoteTotal += quoteQty
+		sideLevels = append(sideLevels, bidAskStruct{Price: price, Quantity: quantity, Total: baseTotal, QuoteQty: quoteQty, QuoteTotal: quoteTotal})
+	}
+
+	for id := range sideLevels {
+		sideLevels[id].Percentage = utils.TruncateFloat((sideLevels[id].Total/baseTotal)*100.00, 3)
+	}
+	return
+}
+
+// clientDepth returns the levels a side sent to a client asking for depth, orderbook.clientdepth when
+// it asks for none, deeper books being sent only to the clients asking for them.
+func clientDepth(depth int) int {
+	if depth <= 0 {
+		return utils.Config.Orderbook.ClientDepth
+	}
+	return depth
+}
+
+// withDepth returns a copy of the orderbook holding at most depth levels a side, with its totals and
+// percentages taken over the levels kept.
+func (orderbook orderbooks) withDepth(depth int) orderbooks {
+	if depth <= 0 || (len(orderbook.Bids) <= depth && len(orderbook.Asks) <= depth) {
+		return orderbook
+	}
+
+	truncate := func(levels []bidAskStruct) (kept []bidAskStruct, baseTotal, quoteTotal float64) {
+		if len(levels) > depth {
+			levels = levels[:depth]
+		}
+		kept = append(kept, levels...)
+		if len(kept) == 0 {
+			return
 		}
-		orderbookListMutex.RUnlock()
 
-		for _, orderbook := range unlockedOrderbookList {
-			wsConn.WriteJSON(orderbook)
+		baseTotal = kept[len(kept)-1].Total
+		quoteTotal = kept[len(kept)-1].QuoteTotal
+		for id := range kept {
+			kept[id].Percentage = utils.TruncateFloat((kept[id].Total/baseTotal)*100.00, 3)
 		}
+		return
+	}
+
+	orderbook.Bids, orderbook.BidsBaseTotal, orderbook.BidsQuoteTotal = truncate(orderbook.Bids)
+	orderbook.Asks, orderbook.AsksBaseTotal, orderbook.AsksQuoteTotal = truncate(orderbook.Asks)
+	return orderbook
+}
+
+func restHandlerOrderbook(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	pair := strings.ToUpper(query.Get("pair"))
+	if pair == "" {
+		http.Error(httpRes, "Missing pair parameter", http.StatusBadRequest)
+		return
+	}
+
+	exchange := strings.ToLower(query.Get("exchange"))
+	if exchange == "" {
+		exchange = "binance"
+	}
+
+	depth, _ := strconv.Atoi(query.Get("depth"))
+
+	orderbookMutex.RLock()
+	orderbook := getOrderbook(pair, exchange).withDepth(clientDepth(depth))
+	orderbookMutex.RUnlock()
+
+	if orderbook.Pair == "" {
+		http.Error(httpRes, fmt.Sprintf("No orderbook for %s on %s", pair, exchange), http.StatusNotFound)
+		return
+	}
 
-		wsConnOrderbooksMutex.Lock()
-		wsConnOrderbooks[wsConn] = true
-		wsConnOrderbooksMutex.Unlock()
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(orderbook)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
+
+func wsHandlerOrderbooks(httpRes http.ResponseWriter, httpReq *http.Request) {
+	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
+
+		// depth sets the levels a side sent to this connection, up to orderbook.depth
+		client := wsHubOrderbooks.register(wsConn, httpReq, sendOrderbooks)
+		defer client.close()
+
+		for {
+			var msg struct {
+				wsSubscriptionMsgType
+				Depth int
+			}
+
+			if err := wsConn.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			if client.handle(msg.wsSubscriptionMsgType) {
+				continue
+			}
+
+			switch msg.Action {
+			case "depth":
+				client.setDepth(msg.Depth)
+				client.reply(msg.ID, msg.Action, msg.Depth, nil)
+			}
+		}
 
 		/*
 
@@ -145,23 +254,23 @@ func wsHandlerOrderbooks(httpRes http.ResponseWriter, httpReq *http.Request) {
 	}
 }
 
-func wsHandlerOrderbookBroadcast() {
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			wsConnOrderbooksMutex.Lock()
-			for wsConn := range wsConnOrderbooks {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnOrderbooks, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnOrderbooksMutex.Unlock()
+// sendOrderbooks queues for a client the orderbooks subscriptions match, at their depth.
+func sendOrderbooks(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	var unlockedOrderbookList []orderbooks
+	orderbookListMutex.RLock()
+	for _, orderbook := range orderbookList {
+		if subscriptions.matches(orderbook.Pair, orderbook.Exchange, "") {
+			unlockedOrderbookList = append(unlockedOrderbookList, orderbook)
 		}
-	}()
+	}
+	orderbookListMutex.RUnlock()
+
+	for _, orderbook := range unlockedOrderbookList {
+		client.send("orderbook", fmt.Sprintf("%s-%s", orderbook.Pair, strings.ToLower(orderbook.Exchange)), orderbook.withDepth(clientDepth(subscriptions.Depth)))
+	}
+}
 
+func wsHandlerOrderbookBroadcast() {
 	go func() {
 		ticker := time.NewTicker(time.Second)
 		defer ticker.Stop()
@@ -177,16 +286,22 @@ func wsHandlerOrderbookBroadcast() {
 
 	go func() {
 		for orderbook := range wsBroadcastOrderBook {
-			wsConnOrderbooksMutex.Lock()
-			for wsConn := range wsConnOrderbooks {
-				orderbookMutex.RLock()
-				if err := wsConn.WriteJSON(orderbook); err != nil {
-					delete(wsConnOrderbooks, wsConn)
-					wsConn.Close()
-				}
-				orderbookMutex.RUnlock()
+			book, ok := orderbook.(orderbooks)
+			if !ok {
+				wsHubOrderbooks.broadcast("orderbook", "", orderbook, nil)
+				continue
 			}
-			wsConnOrderbooksMutex.Unlock()
+
+			wsHubOrderbooks.broadcast("orderbook", fmt.Sprintf("%s-%s", book.Pair, strings.ToLower(book.Exchange)), book,
+				func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+					if !subscriptions.matches(book.Pair, book.Exchange, "") {
+						return nil, false
+					}
+
+					orderbookMutex.RLock()
+					defer orderbookMutex.RUnlock()
+					return book.withDepth(clientDepth(subscriptions.Depth)), true
+				})
 		}
 	}()
 }
@@ -13,8 +13,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
@@ -23,9 +21,7 @@ var (
 
 	orderListMutex    = sync.RWMutex{}
 	orderListMapMutex = sync.RWMutex{}
-	wsConnOrdersMutex = sync.RWMutex{}
 
-	wsConnOrders     = make(map[*websocket.Conn]bool)
 	wsBroadcastOrder = make(chan []models.Order, 10240)
 )
 
@@ -33,6 +29,12 @@ type orderMsgType struct {
 	Action, Start,
 	Stop string
 	Order models.Order
+
+	// Event and Subscriptions complete a subscribe or unsubscribe by Order.Pair and Order.Exchange,
+	// ID is echoed in the response and Seq starts a resync
+	ID, Event     string
+	Seq           uint64
+	Subscriptions []wsSubscriptionType
 }
 
 func getOrder(orderID uint64, orderExchange string) (order models.Order) {
@@ -57,14 +59,8 @@ func getOrder(orderID uint64, orderExchange string) (order models.Order) {
 func wsHandlerOrders(httpRes http.ResponseWriter, httpReq *http.Request) {
 	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
 
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
-
-		wsConnOrdersMutex.Lock()
-		wsConnOrders[wsConn] = true
-		wsConnOrdersMutex.Unlock()
+		client := wsHubOrders.register(wsConn, httpReq, nil)
+		defer client.close()
 
 		for {
 			var msg = orderMsgType{}
@@ -77,14 +73,23 @@ func wsHandlerOrders(httpRes http.ResponseWriter, httpReq *http.Request) {
 				continue
 			}
 
+			if client.handle(wsSubscriptionMsgType{ID: msg.ID, Action: msg.Action, Pair: msg.Order.Pair, Exchange: msg.Order.Exchange,
+				Event: msg.Event, Seq: msg.Seq, Subscriptions: msg.Subscriptions}) {
+				continue
+			}
+
+			// the exchange outcome of query, cancel and create arrives later on the orders feed, the
+			// response only acknowledging the request was sent on
 			switch msg.Action {
 			case "refenable":
 				msg.Order.RefEnabled = 1
 				updateOrderAndSave(msg.Order, true)
+				client.reply(msg.ID, msg.Action, msg.Order, nil)
 
 			case "refdisable":
 				msg.Order.RefEnabled = 0
 				updateOrderAndSave(msg.Order, true)
+				client.reply(msg.ID, msg.Action, msg.Order, nil)
 
 			case "list":
 				var searchMsg = searchOrderMsgType{
@@ -92,11 +97,7 @@ func wsHandlerOrders(httpRes http.ResponseWriter, httpReq *http.Request) {
 					Stop:  msg.Stop,
 					Start: msg.Start,
 				}
-				filteredOrderList := searchOrderSQL(searchMsg)
-				select {
-				case wsBroadcastOrder <- filteredOrderList:
-				default:
-				}
+				client.reply(msg.ID, msg.Action, searchOrderSQL(searchMsg), nil)
 
 				go func() { //not needed as this causes race errors and data upate issues
 					switch msg.Order.Exchange {
@@ -120,6 +121,7 @@ func wsHandlerOrders(httpRes http.ResponseWriter, httpReq *http.Request) {
 				case "crex24":
 					crex24OrderQuery(msg.Order.OrderID)
 				}
+				client.reply(msg.ID, msg.Action, msg.Order, nil)
 
 			case "cancel":
 				switch msg.Order.Exchange {
@@ -128,18 +130,33 @@ func wsHandlerOrders(httpRes http.ResponseWriter, httpReq *http.Request) {
 				case "crex24":
 					crex24OrderCancel(msg.Order.OrderID)
 				}
+				client.reply(msg.ID, msg.Action, msg.Order, nil)
 
 			case "create":
 
 				// TakeProfit, StopLoss
 
+				if err := checkSlippage(msg.Order.Pair, msg.Order.Exchange, msg.Order.Side, msg.Order.Quantity); err != nil {
+					wsBroadcastNotification <- notifications{Type: "info", Title: "*Create Order*", Message: err.Error(), Severity: "warning", Source: "risk", Pair: msg.Order.Pair}
+					client.reply(msg.ID, msg.Action, msg.Order, err)
+					continue
+				}
+
 				//Get particular Market.
 				switch msg.Order.Exchange {
 				default:
-					binanceOrderCreate(msg.Order.Pair, msg.Order.Side, strconv.FormatFloat(msg.Order.Price, 'f', -1, 64), strconv.FormatFloat(msg.Order.Quantity, 'f', -1, 64), msg.Order.Stoploss, msg.Order.Takeprofit, msg.Order.AutoRepeat, 0)
+					binanceOrderCreate(msg.Order.Pair, msg.Order.Side, strconv.FormatFloat(msg.Order.Price, 'f', -1, 64), strconv.FormatFloat(msg.Order.Quantity, 'f', -1, 64), msg.Order.Stoploss, msg.Order.Takeprofit, msg.Order.AutoRepeat, 0, 0)
 				case "crex24":
-					crex24OrderCreate(msg.Order.Pair, msg.Order.Side, msg.Order.Price, msg.Order.Quantity, msg.Order.Stoploss, msg.Order.Takeprofit, msg.Order.AutoRepeat, 0)
+					crex24OrderCreate(msg.Order.Pair, msg.Order.Side, msg.Order.Price, msg.Order.Quantity, msg.Order.Stoploss, msg.Order.Takeprofit, msg.Order.AutoRepeat, 0, 0)
+				}
+				client.reply(msg.ID, msg.Action, msg.Order, nil)
+
+			case "execute":
+				opportunity, err := executeOpportunity(msg.Order.OpportunityID, msg.Order.Quantity, msg.Order.Price)
+				if err != nil {
+					wsBroadcastNotification <- notifications{Type: "info", Title: "*Execute Opportunity*", Message: err.Error()}
 				}
+				client.reply(msg.ID, msg.Action, opportunity, err)
 
 			}
 		}
@@ -148,31 +165,20 @@ func wsHandlerOrders(httpRes http.ResponseWriter, httpReq *http.Request) {
 
 func wsHandlerOrderBroadcast() {
 	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-
-		for range ticker.C {
-			wsConnOrdersMutex.Lock()
-			for wsConn := range wsConnOrders {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnOrders, wsConn)
-					wsConn.Close()
+		for order := range wsBroadcastOrder {
+			wsHubOrders.broadcast("orders", "", order, func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+				if subscriptions.all() {
+					return order, true
 				}
-			}
-			wsConnOrdersMutex.Unlock()
-		}
-	}()
 
-	go func() {
-		for order := range wsBroadcastOrder {
-			wsConnOrdersMutex.Lock()
-			for wsConn := range wsConnOrders {
-				if err := wsConn.WriteJSON(order); err != nil {
-					delete(wsConnOrders, wsConn)
-					wsConn.Close()
+				var filteredOrders []models.Order
+				for _, item := range order {
+					if subscriptions.matches(item.Pair, item.Exchange, "", item.Status, item.Side) {
+						filteredOrders = append(filteredOrders, item)
+					}
 				}
-			}
-			wsConnOrdersMutex.Unlock()
+				return filteredOrders, len(filteredOrders) > 0
+			})
 		}
 	}()
 }
@@ -218,6 +224,10 @@ func updateOrderAndSave(order models.Order, save bool) {
 }
 
 func saveOrder(order models.Order) {
+	// a replay trips the orders of the database in memory only
+	if isReplayMode() {
+		return
+	}
 
 	//convert order to a map interface using json
 	orderMap := make(map[string]interface{})
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RecordOrderbook = "orderbook"
+	RecordMarket    = "market"
+	RecordTrade     = "trade"
+	RecordAnalysis  = "analysis"
+
+	recordingExtension = ".jsonl.gz"
+)
+
+// recordType is one line of a recording, holding the orderbook, market, trade or analysis named by
+// Kind, the analysis being the summaries the strategies acted on.
+type recordType struct {
+	Timestamp time.Time
+	Kind      string
+
+	Orderbook *orderbooks    `json:",omitempty"`
+	Market    *models.Market `json:",omitempty"`
+	Trade     *trades        `json:",omitempty"`
+	Analysis  *analysisType  `json:",omitempty"`
+}
+
+type recordingFileType struct {
+	Name     string
+	Size     int64
+	Modified time.Time
+}
+
+type recorderStatusType struct {
+	Enabled    bool
+	Path       string
+	ReplayMode bool
+	Replaying  bool
+	Replay     []string
+	Files      []recordingFileType
+}
+
+var (
+	chanRecorder = make(chan recordType, 10240)
+
+	recorderMutex = sync.Mutex{}
+	recorderFile  *os.File
+	recorderGzip  *gzip.Writer
+
+	replayMutex   = sync.RWMutex{}
+	replayRunning bool
+	replayFiles   []string
+	replayStop    = make(chan bool, 1)
+)
+
+// isReplaying reports whether a recording is being fed back.
+func isReplaying() bool {
+	replayMutex.RLock()
+	defer replayMutex.RUnlock()
+	return replayRunning
+}
+
+// isReplayMode reports whether the process was started to replay recordings instead of the live
+// streams, in which case it sends no orders, writes no database rows and dispatches no notifiers.
+func isReplayMode() bool {
+	return utils.Config.Recorder.ReplayMode
+}
+
+// recordEvent queues an orderbook, market, trade or analysis for the recorder of enabled markets.
+func recordEvent(record recordType) {
+	if !utils.Config.Recorder.Enabled || isReplayMode() {
+		return
+	}
+
+	if record.Orderbook != nil {
+		orderbook := record.Orderbook.withDepth(utils.Config.Recorder.Depth)
+		record.Orderbook = &orderbook
+	}
+
+	record.Timestamp = time.Now()
+	select {
+	case chanRecorder <- record:
+	default:
+	}
+}
+
+// openRecording starts the gzip file of the rotation period holding now.
+func openRecording(now time.Time) error {
+	if err := os.MkdirAll(utils.Config.Recorder.Path, 0755); err != nil {
+		return err
+	}
+
+	fileName := now.Truncate(utils.Config.Recorder.Rotate).Format("2006-01-02T150405") + recordingExtension
+	file, err := os.OpenFile(filepath.Join(utils.Config.Recorder.Path, fileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	recorderFile = file
+	recorderGzip = gzip.NewWriter(file)
+	return nil
+}
+
+// closeRecording flushes and closes the current recording, leaving a complete gzip member on disk.
+func closeRecording() {
+	recorderMutex.Lock()
+	defer recorderMutex.Unlock()
+
+	if recorderGzip != nil {
+		recorderGzip.Close()
+		recorderGzip = nil
+	}
+
+	if recorderFile != nil {
+		recorderFile.Close()
+		recorderFile = nil
+	}
+}
+
+// GoRecorder writes the queued records as gzip compressed json lines, one file per rotation period,
+// flushing every few seconds so a recording can be read while it is still being written.
+func GoRecorder() {
+	if !utils.Config.Recorder.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second * 5)
+	defer ticker.Stop()
+
+	var period time.Time
+	for {
+		select {
+		case record := <-chanRecorder:
+			recordBytes, err := json.Marshal(record)
+			if err != nil {
+				log.Println(err.Error())
+				continue
+			}
+
+			if recordPeriod := record.Timestamp.Truncate(utils.Config.Recorder.Rotate); !recordPeriod.Equal(period) {
+				closeRecording()
+
+				recorderMutex.Lock()
+				err = openRecording(record.Timestamp)
+				recorderMutex.Unlock()
+				if err != nil {
+					log.Printf("Recorder: %v \n", err)
+					continue
+				}
+				period = recordPeriod
+			}
+
+			recorderMutex.Lock()
+			if recorderGzip != nil {
+				recorderGzip.Write(append(recordBytes, '\n'))
+			}
+			recorderMutex.Unlock()
+
+		case <-ticker.C:
+			recorderMutex.Lock()
+			if recorderGzip != nil {
+				recorderGzip.Flush()
+			}
+			recorderMutex.Unlock()
+		}
+	}
+}
+
+func listRecordings() (files []recordingFileType) {
+	entries, err := os.ReadDir(utils.Config.Recorder.Path)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), recordingExtension) {
+			continue
+		}
+
+		if info, err := entry.Info(); err == nil {
+			files = append(files, recordingFileType{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime()})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return
+}
+
+// replayRecord feeds a record back into the in-memory state and the channels the live streams feed,
+// waiting on each channel so an accelerated replay drops nothing.
+func replayRecord(record recordType) {
+	switch record.Kind {
+	case RecordOrderbook:
+		if record.Orderbook == nil {
+			return
+		}
+		orderbookMutex.Lock()
+		updateOrderbook(*record.Orderbook)
+		orderbookMutex.Unlock()
+
+		chanStoplossTakeProfit <- *record.Orderbook
+		chanOrderbookAnalytics <- *record.Orderbook
+
+	case RecordMarket:
+		if record.Market == nil {
+			return
+		}
+		updateMarket(*record.Market)
+		wsBroadcastMarket <- *record.Market
+		chanAlertMarkets <- *record.Market
+
+	case RecordTrade:
+		if record.Trade == nil {
+			return
+		}
+		updateTrade(*record.Trade)
+		aggregateTrade(record.Trade.Pair, record.Trade.Exchange, record.Trade.Price, record.Trade.Quantity, record.Trade.IsBuyerMaker, record.Timestamp)
+
+	case RecordAnalysis:
+		if record.Analysis == nil {
+			return
+		}
+		updateAnalysis(*record.Analysis)
+	}
+}
+
+// replayRecordings feeds the records of the named recordings back in order, between starttime and
+// endtime when set, at speed times the recorded pace or as fast as possible when speed is 0.
+func replayRecordings(fileNames []string, speed float64, starttime, endtime time.Time) (err error) {
+	replayMutex.Lock()
+	if replayRunning {
+		replayMutex.Unlock()
+		return errors.New("A replay is already running")
+	}
+	replayRunning = true
+	replayFiles = fileNames
+	replayMutex.Unlock()
+
+	select {
+	case <-replayStop:
+	default:
+	}
+
+	defer func() {
+		replayMutex.Lock()
+		replayRunning = false
+		replayFiles = nil
+		replayMutex.Unlock()
+	}()
+
+	var previous time.Time
+	for _, fileName := range fileNames {
+		file, err := os.Open(filepath.Join(utils.Config.Recorder.Path, filepath.Base(fileName)))
+		if err != nil {
+			return err
+		}
+
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		scanner := bufio.NewScanner(gzipReader)
+		scanner.Buffer(make([]byte, 1024*1024), 64*1024*1024)
+		for scanner.Scan() {
+			var record recordType
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				continue
+			}
+
+			if (!starttime.IsZero() && record.Timestamp.Before(starttime)) || (!endtime.IsZero() && record.Timestamp.After(endtime)) {
+				continue
+			}
+
+			if speed > 0 && !previous.IsZero() && record.Timestamp.After(previous) {
+				select {
+				case <-replayStop:
+					file.Close()
+					return nil
+				case <-time.After(time.Duration(float64(record.Timestamp.Sub(previous)) / speed)):
+				}
+			}
+			previous = record.Timestamp
+
+			replayRecord(record)
+		}
+
+		// a recording cut short by a restart ends without its gzip footer
+		if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("Replay %s: %v \n", fileName, err)
+		}
+		file.Close()
+
+		select {
+		case <-replayStop:
+			return nil
+		default:
+		}
+	}
+	return nil
+}
+
+func restHandlerRecorder(httpRes http.ResponseWriter, httpReq *http.Request) {
+	replayMutex.RLock()
+	status := recorderStatusType{
+		Enabled:    utils.Config.Recorder.Enabled,
+		Path:       utils.Config.Recorder.Path,
+		ReplayMode: isReplayMode(),
+		Replaying:  replayRunning,
+		Replay:     replayFiles,
+		Files:      listRecordings(),
+	}
+	replayMutex.RUnlock()
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(status)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
+
+func restHandlerRecorderReplay(httpRes http.ResponseWriter, httpReq *http.Request) {
+	// a replay feeds the strategies, alerts and orders of the process, so it must not run beside the live streams
+	if !isReplayMode() {
+		http.Error(httpRes, "Replay is only available when started with recorder.replaymode", http.StatusConflict)
+		return
+	}
+
+	if httpReq.Method == "DELETE" {
+		if !isReplaying() {
+			http.Error(httpRes, "No replay is running", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case replayStop <- true:
+		default:
+		}
+		httpRes.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	query := httpReq.URL.Query()
+
+	var fileNames []string
+	for _, fileName := range strings.Split(query.Get("files"), ",") {
+		if fileName = filepath.Base(strings.TrimSpace(fileName)); strings.HasSuffix(fileName, recordingExtension) {
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
+	if len(fileNames) == 0 {
+		http.Error(httpRes, "Missing files parameter", http.StatusBadRequest)
+		return
+	}
+	sort.Strings(fileNames)
+
+	speed := utils.Config.Recorder.ReplaySpeed
+	if query.Get("speed") != "" {
+		var err error
+		if speed, err = strconv.ParseFloat(query.Get("speed"), 64); err != nil || speed < 0 {
+			http.Error(httpRes, "Invalid speed parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var starttime, endtime time.Time
+	var err error
+	if query.Get("starttime") != "" {
+		if starttime, err = time.ParseInLocation(time.DateTime, query.Get("starttime"), time.Local); err != nil {
+			http.Error(httpRes, "Invalid starttime parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if query.Get("endtime") != "" {
+		if endtime, err = time.ParseInLocation(time.DateTime, query.Get("endtime"), time.Local); err != nil {
+			http.Error(httpRes, "Invalid endtime parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if isReplaying() {
+		http.Error(httpRes, "A replay is already running", http.StatusConflict)
+		return
+	}
+
+	go func() {
+		if err := replayRecordings(fileNames, speed, starttime, endtime); err != nil {
+			log.Printf("Replay: %v \n", err)
+			return
+		}
+		wsBroadcastNotification <- notifications{Type: "info", Title: "*Replay*", Message: fmt.Sprintf("Finished replaying %s", strings.Join(fileNames, ", "))}
+	}()
+
+	httpRes.WriteHeader(http.StatusAccepted)
+}
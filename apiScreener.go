@@ -0,0 +1,445 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// screenerKlinesWeight is the request weight of the klines of one interval, which grows with the
+// candles an analysis fetches from the default 60 of a kline request.
+func screenerKlinesWeight() int {
+	switch limit := analysisKlinesLimit(60); {
+	case limit <= 100:
+		return 1
+	case limit <= 500:
+		return 2
+	}
+	return 5
+}
+
+var (
+	screenerAndRegex    = regexp.MustCompile(`(?i)\s+AND\s+`)
+	screenerClauseRegex = regexp.MustCompile(`^\s*([A-Za-z0-9.]+)\s*(>=|<=|==|!=|=|>|<)\s*(.+?)\s*$`)
+
+	// Volume and VolumeQuote read the 24h ticker volumes, the same for every market, rather than the
+	// current candle's an enabled market streams
+	screenerMarketFields = map[string]func(models.Market) interface{}{
+		"Pair":               func(m models.Market) interface{} { return m.Pair },
+		"Exchange":           func(m models.Market) interface{} { return m.Exchange },
+		"Status":             func(m models.Market) interface{} { return m.Status },
+		"BaseAsset":          func(m models.Market) interface{} { return m.BaseAsset },
+		"QuoteAsset":         func(m models.Market) interface{} { return m.QuoteAsset },
+		"Price":              func(m models.Market) interface{} { return m.Price },
+		"Volume":             func(m models.Market) interface{} { return m.Volume24h },
+		"VolumeQuote":        func(m models.Market) interface{} { return m.VolumeQuote24h },
+		"NumOfTrades":        func(m models.Market) interface{} { return float64(m.NumOfTrades) },
+		"PriceChange":        func(m models.Market) interface{} { return m.PriceChange },
+		"PriceChangePercent": func(m models.Market) interface{} { return m.PriceChangePercent },
+		"HighPrice":          func(m models.Market) interface{} { return m.HighPrice },
+		"LowPrice":           func(m models.Market) interface{} { return m.LowPrice },
+		"RSI":                func(m models.Market) interface{} { return marketIndicator(m, m.RSI) },
+		"BandPosition": func(m models.Market) interface{} {
+			return marketIndicator(m, bandPosition(m.Price, m.LowerBand, m.UpperBand))
+		},
+	}
+
+	screenerSummaryFields = map[string]func(utils.Summary) interface{}{
+		"Trend":      func(s utils.Summary) interface{} { return s.Trend },
+		"RSI":        func(s utils.Summary) interface{} { return s.RSI },
+		"Close":      func(s utils.Summary) interface{} { return s.Candle.Close },
+		"Pattern":    func(s utils.Summary) interface{} { return s.Pattern.Candle },
+		"PriorTrend": func(s utils.Summary) interface{} { return s.Pattern.PriorTrend },
+		"BandPosition": func(s utils.Summary) interface{} {
+			return bandPosition(s.Candle.Close, s.BollingerBands["lower"], s.BollingerBands["upper"])
+		},
+	}
+
+	screenerWeightMutex  = sync.Mutex{}
+	screenerWeightSpent  int
+	screenerWeightWindow time.Time
+)
+
+type screenerCondition struct {
+	Interval, Field,
+	Operator, Value string
+}
+
+type screenerRowType struct {
+	Pair      string
+	Exchange  string
+	Market    map[string]interface{}
+	Intervals map[string]map[string]interface{}
+
+	market    models.Market
+	summaries map[string]utils.Summary
+}
+
+type screenerResultType struct {
+	Total int
+	Page  int
+	Limit int
+	Rows  []screenerRowType
+}
+
+// marketIndicator returns an indicator kept from the streams of an enabled market, or nil for any
+// other market, whose indicators are unavailable rather than 0. A filter on an unavailable value
+// does not match, and the interval fields such as 1h.RSI compute it for every market.
+func marketIndicator(market models.Market, value float64) interface{} {
+	if market.Status != "enabled" {
+		return nil
+	}
+	return value
+}
+
+// waitScreenerWeight blocks until weight fits in the screener's budget of the current minute, so
+// screening every market cannot spend the request weight the trading streams rely on.
+func waitScreenerWeight(weight int) {
+	if utils.Config.Screener.Weight <= 0 {
+		return
+	}
+
+	for {
+		screenerWeightMutex.Lock()
+		if time.Since(screenerWeightWindow) >= time.Minute {
+			screenerWeightWindow = time.Now()
+			screenerWeightSpent = 0
+		}
+
+		if screenerWeightSpent+weight <= utils.Config.Screener.Weight {
+			screenerWeightSpent += weight
+			screenerWeightMutex.Unlock()
+			return
+		}
+		wait := time.Minute - time.Since(screenerWeightWindow)
+		screenerWeightMutex.Unlock()
+		time.Sleep(wait)
+	}
+}
+
+// missingScreenerIntervals returns the intervals the cached analysis of a row lacks, which would be
+// fetched as klines.
+func (row *screenerRowType) missingScreenerIntervals(intervals []string) (missing []string) {
+	cached := getAnalysis(row.Pair, row.Exchange)
+	for _, interval := range intervals {
+		if _, ok := cached.Intervals[interval]; !ok {
+			missing = append(missing, interval)
+		}
+	}
+	return
+}
+
+// bandPosition places price within a bollinger band, 0 at the lower and 1 at the upper band.
+func bandPosition(price, lower, upper float64) float64 {
+	if upper <= lower {
+		return 0
+	}
+	return utils.TruncateFloat((price-lower)/(upper-lower), 3)
+}
+
+// parseScreenerFilter splits a filter such as "VolumeQuote > 1000000 AND 1h.RSI < 30" into its
+// conditions; fields prefixed with an interval are read from that interval's Summary.
+func parseScreenerFilter(filter string) (conditions []screenerCondition, err error) {
+	if strings.TrimSpace(filter) == "" {
+		return
+	}
+
+	for _, clause := range screenerAndRegex.Split(strings.TrimSpace(filter), -1) {
+		match := screenerClauseRegex.FindStringSubmatch(clause)
+		if match == nil {
+			return nil, fmt.Errorf("Invalid filter condition: %s", clause)
+		}
+
+		condition := screenerCondition{Field: match[1], Operator: match[2], Value: strings.Trim(match[3], `"'`)}
+		if condition.Operator == "=" {
+			condition.Operator = "=="
+		}
+
+		if interval, field, found := strings.Cut(condition.Field, "."); found {
+			if _, ok := klineIntervalDurations[interval]; !ok {
+				return nil, fmt.Errorf("Invalid filter interval: %s", interval)
+			}
+			if _, ok := screenerSummaryFields[field]; !ok {
+				return nil, fmt.Errorf("Invalid filter field: %s", condition.Field)
+			}
+			condition.Interval, condition.Field = interval, field
+		} else if _, ok := screenerMarketFields[condition.Field]; !ok {
+			return nil, fmt.Errorf("Invalid filter field: %s", condition.Field)
+		}
+		conditions = append(conditions, condition)
+	}
+	return
+}
+
+// screenerValue reads a market field, or an interval summary field when interval is set.
+func (row *screenerRowType) screenerValue(interval, field string) (interface{}, bool) {
+	if interval == "" {
+		if accessor, ok := screenerMarketFields[field]; ok {
+			return accessor(row.market), true
+		}
+		return nil, false
+	}
+
+	summary, ok := row.summaries[interval]
+	accessor, known := screenerSummaryFields[field]
+	if !ok || !known {
+		return nil, false
+	}
+	return accessor(summary), true
+}
+
+// compareScreenerValues compares numbers numerically and anything else as case-insensitive text.
+func compareScreenerValues(value interface{}, operator, expected string) bool {
+	if number, ok := value.(float64); ok {
+		target, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false
+		}
+
+		switch operator {
+		case ">":
+			return number > target
+		case ">=":
+			return number >= target
+		case "<":
+			return number < target
+		case "<=":
+			return number <= target
+		case "==":
+			return number == target
+		case "!=":
+			return number != target
+		}
+		return false
+	}
+
+	text := fmt.Sprintf("%v", value)
+	switch operator {
+	case "==":
+		return strings.EqualFold(text, expected)
+	case "!=":
+		return !strings.EqualFold(text, expected)
+	}
+	return false
+}
+
+// matchScreenerConditions checks the conditions on the market only (onInterval false) or on the
+// interval summaries only (onInterval true), so markets can be narrowed before fetching klines.
+func (row *screenerRowType) matchScreenerConditions(conditions []screenerCondition, onInterval bool) bool {
+	for _, condition := range conditions {
+		if (condition.Interval != "") != onInterval {
+			continue
+		}
+
+		value, ok := row.screenerValue(condition.Interval, condition.Field)
+		if !ok || value == nil || !compareScreenerValues(value, condition.Operator, condition.Value) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchScreenerSummaries fills the summaries of every row on a bounded pool of workers, reusing the
+// cached analysis of enabled markets and fetching klines only for the intervals it lacks, within
+// screener.weight.
+func fetchScreenerSummaries(rows []*screenerRowType, intervals []string) {
+	workers := utils.Config.Screener.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan *screenerRowType)
+	wg := sync.WaitGroup{}
+	for worker := 0; worker < workers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range jobs {
+				row.summaries = make(map[string]utils.Summary)
+
+				var missing []string
+				cached := getAnalysis(row.Pair, row.Exchange)
+				for _, interval := range intervals {
+					if summary, ok := cached.Intervals[interval]; ok {
+						row.summaries[interval] = summary
+					} else {
+						missing = append(missing, interval)
+					}
+				}
+
+				if len(missing) == 0 {
+					continue
+				}
+
+				waitScreenerWeight(screenerKlinesWeight() * len(missing))
+				analysis, err := retrieveMarketPairAnalysis(row.Pair, row.Exchange, "", "", "", strings.Join(missing, ","))
+				if err != nil {
+					log.Println(err.Error())
+					continue
+				}
+				for interval, summary := range analysis.Intervals {
+					row.summaries[interval] = summary
+				}
+			}
+		}()
+	}
+
+	for _, row := range rows {
+		jobs <- row
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func restHandlerScreener(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	exchange := query.Get("exchange")
+	filter := query.Get("filter")
+	sortBy := query.Get("sort")
+	intervalsVar := query.Get("intervals")
+
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(query.Get("limit"))
+	if err != nil || limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	conditions, err := parseScreenerFilter(filter)
+	if err != nil {
+		http.Error(httpRes, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sortDesc := strings.HasPrefix(sortBy, "-")
+	sortInterval, sortField, sortOnInterval := strings.Cut(strings.TrimPrefix(sortBy, "-"), ".")
+	if !sortOnInterval {
+		sortInterval, sortField = "", sortInterval
+	}
+
+	intervalSet := make(map[string]bool)
+	var intervals []string
+	addInterval := func(interval string) {
+		if interval != "" && !intervalSet[interval] {
+			intervalSet[interval] = true
+			intervals = append(intervals, interval)
+		}
+	}
+	for _, interval := range strings.Split(intervalsVar, ",") {
+		if _, ok := klineIntervalDurations[strings.TrimSpace(interval)]; ok {
+			addInterval(strings.TrimSpace(interval))
+		}
+	}
+	for _, condition := range conditions {
+		addInterval(condition.Interval)
+	}
+	if sortOnInterval {
+		addInterval(sortInterval)
+	}
+
+	var rows []*screenerRowType
+	marketListMutex.RLock()
+	for _, market := range marketList {
+		if exchange != "" && !strings.EqualFold(market.Exchange, exchange) {
+			continue
+		}
+		rows = append(rows, &screenerRowType{Pair: market.Pair, Exchange: market.Exchange, market: market})
+	}
+	marketListMutex.RUnlock()
+
+	var filteredRows []*screenerRowType
+	for _, row := range rows {
+		if row.matchScreenerConditions(conditions, false) {
+			filteredRows = append(filteredRows, row)
+		}
+	}
+
+	if len(intervals) > 0 {
+		fetching := 0
+		for _, row := range filteredRows {
+			if len(row.missingScreenerIntervals(intervals)) > 0 {
+				fetching++
+			}
+		}
+
+		if utils.Config.Screener.MaxFetch > 0 && fetching > utils.Config.Screener.MaxFetch {
+			http.Error(httpRes, fmt.Sprintf("Interval fields need klines of %d markets, more than the %d allowed: narrow the filter with market fields such as VolumeQuote or Status",
+				fetching, utils.Config.Screener.MaxFetch), http.StatusBadRequest)
+			return
+		}
+
+		fetchScreenerSummaries(filteredRows, intervals)
+
+		rows, filteredRows = filteredRows, nil
+		for _, row := range rows {
+			if row.matchScreenerConditions(conditions, true) {
+				filteredRows = append(filteredRows, row)
+			}
+		}
+	}
+
+	if sortField != "" {
+		sort.SliceStable(filteredRows, func(i, j int) bool {
+			a, _ := filteredRows[i].screenerValue(sortInterval, sortField)
+			b, _ := filteredRows[j].screenerValue(sortInterval, sortField)
+
+			// unavailable values sort last either way
+			if a == nil || b == nil {
+				return a != nil
+			}
+
+			numberA, isNumberA := a.(float64)
+			numberB, isNumberB := b.(float64)
+			less := fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
+			if isNumberA && isNumberB {
+				less = numberA < numberB
+			}
+
+			if sortDesc {
+				return !less && a != b
+			}
+			return less
+		})
+	}
+
+	result := screenerResultType{Total: len(filteredRows), Page: page, Limit: limit}
+	for index := (page - 1) * limit; index < len(filteredRows) && index < page*limit; index++ {
+		row := filteredRows[index]
+
+		row.Market = make(map[string]interface{})
+		for field, accessor := range screenerMarketFields {
+			row.Market[field] = accessor(row.market)
+		}
+
+		row.Intervals = make(map[string]map[string]interface{})
+		for interval, summary := range row.summaries {
+			row.Intervals[interval] = make(map[string]interface{})
+			for field, accessor := range screenerSummaryFields {
+				row.Intervals[interval][field] = accessor(summary)
+			}
+		}
+		result.Rows = append(result.Rows, *row)
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(result)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
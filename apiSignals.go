@@ -0,0 +1,278 @@
+package main
+
+import (
+	"backpocket/models"
+	"backpocket/utils"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type webhookSignalType struct {
+	// ID names the signal so a resent one is ignored, and Timestamp in unix seconds stands in for
+	// the X-Timestamp header of senders that cannot set headers
+	ID         string
+	Timestamp  int64
+	Secret     string
+	Pair       string
+	Exchange   string
+	Action     string
+	Timeframe  string
+	Price      float64
+	Stoploss   float64
+	Takeprofit float64
+	Size       float64
+	Risk       float64
+	Execute    bool
+}
+
+var (
+	webhookSeen      = make(map[string]time.Time)
+	webhookSeenMutex = sync.Mutex{}
+)
+
+// verifyWebhookSignature accepts a request whose X-Signature header is the hex HMAC-SHA256 of its
+// X-Timestamp header, a dot and the body, or whose X-Webhook-Secret header or Secret field matches
+// the shared secret, for senders like charting alerts that cannot set headers. A timestamp further
+// than webhook.tolerance from now is refused, so a captured request cannot be sent again later.
+func verifyWebhookSignature(httpReq *http.Request, body []byte, signal webhookSignalType) error {
+	secret := utils.Config.Webhook.Secret
+	if secret == "" {
+		return fmt.Errorf("Webhook secret not configured")
+	}
+
+	timestamp := signal.Timestamp
+	if header := httpReq.Header.Get("X-Timestamp"); header != "" {
+		var err error
+		if timestamp, err = strconv.ParseInt(header, 10, 64); err != nil {
+			return fmt.Errorf("Invalid X-Timestamp header")
+		}
+	}
+
+	if signature := httpReq.Header.Get("X-Signature"); signature != "" {
+		if timestamp == 0 {
+			return fmt.Errorf("Missing X-Timestamp header")
+		}
+
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+		mac.Write(body)
+		expected := hex.EncodeToString(mac.Sum(nil))
+		if !hmac.Equal([]byte(strings.TrimPrefix(strings.ToLower(signature), "sha256=")), []byte(expected)) {
+			return fmt.Errorf("Invalid signature")
+		}
+	} else {
+		sharedSecret := httpReq.Header.Get("X-Webhook-Secret")
+		if sharedSecret == "" {
+			sharedSecret = signal.Secret
+		}
+		if subtle.ConstantTimeCompare([]byte(sharedSecret), []byte(secret)) != 1 {
+			return fmt.Errorf("Invalid signature")
+		}
+	}
+
+	if timestamp != 0 && utils.Config.Webhook.Tolerance > 0 {
+		if age := time.Since(time.Unix(timestamp, 0)); age > utils.Config.Webhook.Tolerance || age < -utils.Config.Webhook.Tolerance {
+			return fmt.Errorf("Stale timestamp")
+		}
+	}
+	return nil
+}
+
+// webhookSignalKey names a signal for deduplication by its ID, or by its signature when it has none.
+func webhookSignalKey(httpReq *http.Request, signal webhookSignalType) string {
+	if signal.ID != "" {
+		return "id:" + signal.ID
+	}
+
+	if signature := httpReq.Header.Get("X-Signature"); signature != "" {
+		return "signature:" + strings.TrimPrefix(strings.ToLower(signature), "sha256=")
+	}
+	return ""
+}
+
+// seenWebhookSignal records the key of a signal and reports whether it was already received within
+// webhook.dedupe, forgetting the expired keys as it goes.
+func seenWebhookSignal(key string) bool {
+	webhookSeenMutex.Lock()
+	defer webhookSeenMutex.Unlock()
+
+	for seenKey, seenAt := range webhookSeen {
+		if time.Since(seenAt) > utils.Config.Webhook.Dedupe {
+			delete(webhookSeen, seenKey)
+		}
+	}
+
+	if _, seen := webhookSeen[key]; seen {
+		return true
+	}
+	webhookSeen[key] = time.Now()
+	return false
+}
+
+// forgetWebhookSignal drops the key of a signal that was turned away or could not be saved, so the
+// sender's retry is received rather than taken for a duplicate.
+func forgetWebhookSignal(key string) {
+	if key == "" {
+		return
+	}
+
+	webhookSeenMutex.Lock()
+	delete(webhookSeen, key)
+	webhookSeenMutex.Unlock()
+}
+
+// webhookSignalQuantity returns the size of the signal, or the quantity risking Risk percent of the
+// free quote balance between price and stoploss.
+func webhookSignalQuantity(signal webhookSignalType, market models.Market) (quantity float64, err error) {
+	if signal.Size > 0 {
+		return signal.Size, nil
+	}
+
+	if signal.Risk <= 0 {
+		return 0, nil
+	}
+
+	stopDistance := math.Abs(signal.Price - signal.Stoploss)
+	if signal.Stoploss == 0 || stopDistance == 0 {
+		return 0, fmt.Errorf("Stoploss is required to size a signal by risk")
+	}
+
+	asset := getAsset(market.QuoteAsset, signal.Exchange)
+	riskAmount := asset.Free * signal.Risk / 100
+	if quantity = autoTradeQuantity(market, signal.Price, riskAmount/stopDistance*signal.Price); quantity == 0 {
+		err = fmt.Errorf("Risking %v%% of %v %s is below the market minimums", signal.Risk, asset.Free, market.QuoteAsset)
+	}
+	return
+}
+
+func restHandlerSignalWebhook(httpRes http.ResponseWriter, httpReq *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(httpReq.Body, 1<<16))
+	if err != nil {
+		http.Error(httpRes, "Error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	var signal webhookSignalType
+	if err := json.Unmarshal(body, &signal); err != nil {
+		http.Error(httpRes, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	if err := verifyWebhookSignature(httpReq, body, signal); err != nil {
+		http.Error(httpRes, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if utils.SqlDB == nil {
+		http.Error(httpRes, "Database not configured", http.StatusInternalServerError)
+		return
+	}
+
+	signal.Pair = strings.ToUpper(signal.Pair)
+	signal.Action = strings.ToUpper(signal.Action)
+	signal.Exchange = strings.ToLower(signal.Exchange)
+	if signal.Exchange == "" {
+		signal.Exchange = "binance"
+	}
+
+	if signal.Timeframe == "" {
+		signal.Timeframe = DefaultTimeframe
+	}
+
+	if signal.Pair == "" {
+		http.Error(httpRes, "Missing pair parameter", http.StatusBadRequest)
+		return
+	}
+
+	if signal.Action != "BUY" && signal.Action != "SELL" {
+		http.Error(httpRes, "Action must be BUY or SELL", http.StatusBadRequest)
+		return
+	}
+
+	market := getMarket(signal.Pair, signal.Exchange)
+	if market.Pair == "" {
+		http.Error(httpRes, fmt.Sprintf("Unknown market %s on %s", signal.Pair, signal.Exchange), http.StatusBadRequest)
+		return
+	}
+
+	if signal.Price == 0 {
+		signal.Price = market.Price
+	}
+
+	quantity, err := webhookSignalQuantity(signal, market)
+	if err != nil {
+		http.Error(httpRes, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the key is claimed before the opportunity is saved so concurrent copies of a signal cannot
+	// both get through, and released again if the signal is not kept
+	signalKey := webhookSignalKey(httpReq, signal)
+	if signalKey != "" && seenWebhookSignal(signalKey) {
+		http.Error(httpRes, "Signal was already received", http.StatusConflict)
+		return
+	}
+
+	// only a signal that can neither be received twice nor kept for later may place an order
+	unique := signalKey != "" && (signal.Timestamp != 0 || httpReq.Header.Get("X-Timestamp") != "")
+	if signal.Execute && utils.Config.Webhook.Execute && quantity > 0 && !unique {
+		forgetWebhookSignal(signalKey)
+		http.Error(httpRes, "Executing a signal needs a timestamp and an ID or an X-Signature", http.StatusBadRequest)
+		return
+	}
+
+	opportunity := models.Opportunity{
+		Source:     models.OpportunitySourceWebhook,
+		Pair:       signal.Pair,
+		Action:     signal.Action,
+		Price:      signal.Price,
+		Timeframe:  signal.Timeframe,
+		Exchange:   signal.Exchange,
+		Stoploss:   signal.Stoploss,
+		Takeprofit: signal.Takeprofit,
+		Analysis: models.JSONB{
+			"Size": signal.Size,
+			"Risk": signal.Risk,
+		},
+	}
+	if err := utils.SqlDB.Create(&opportunity).Error; err != nil {
+		forgetWebhookSignal(signalKey)
+		http.Error(httpRes, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	wsBroadcastNotification <- notifications{
+		Type: "info", Title: signal.Action + " *" + signal.Pair + "* (webhook)",
+		Message: fmt.Sprintf("Price: %v | TP: %v | SL: %v", signal.Price, signal.Takeprofit, signal.Stoploss),
+		Source:  "strategy", Pair: signal.Pair, OpportunityID: opportunity.ID,
+	}
+
+	if signal.Execute && utils.Config.Webhook.Execute && quantity > 0 {
+		if opportunity, err = executeOpportunity(opportunity.ID, quantity, signal.Price); err != nil {
+			log.Println(err.Error())
+			http.Error(httpRes, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(opportunity)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type slippageEstimateType struct {
+	Pair, Exchange, Side string
+
+	// Size is the requested size, in the quote asset when Quote is set
+	Size  float64
+	Quote bool
+
+	Quantity, QuoteQty,
+	BestPrice, MidPrice,
+	AveragePrice, WorstPrice,
+	SlippageBps, ImpactBps float64
+
+	LevelsConsumed int
+	// Filled is false when the book ran out of depth before the size was filled
+	Filled bool
+}
+
+// estimateSlippage walks the asks for a BUY or the bids for a SELL until size, in the base asset or
+// in the quote asset when quote is set, is filled, and prices the fill against the best price and
+// the mid price of the book.
+func estimateSlippage(orderbook orderbooks, side string, size float64, quote bool) (estimate slippageEstimateType, err error) {
+	estimate = slippageEstimateType{Pair: orderbook.Pair, Exchange: orderbook.Exchange, Side: strings.ToUpper(side), Size: size, Quote: quote}
+
+	if size <= 0 {
+		err = fmt.Errorf("Size must be positive")
+		return
+	}
+
+	var levels []bidAskStruct
+	switch estimate.Side {
+	case "BUY":
+		levels = orderbook.Asks
+	case "SELL":
+		levels = orderbook.Bids
+	default:
+		err = fmt.Errorf("Side must be BUY or SELL")
+		return
+	}
+
+	if len(levels) == 0 || len(orderbook.Bids) == 0 || len(orderbook.Asks) == 0 {
+		err = fmt.Errorf("No orderbook depth for %s on %s", orderbook.Pair, orderbook.Exchange)
+		return
+	}
+
+	estimate.BestPrice = levels[0].Price
+	estimate.MidPrice = (orderbook.Bids[0].Price + orderbook.Asks[0].Price) / 2
+
+	remaining := size
+	for _, level := range levels {
+		levelSize := level.Quantity
+		if quote {
+			levelSize = level.Quantity * level.Price
+		}
+
+		taken := math.Min(remaining, levelSize)
+		quantity := taken
+		if quote {
+			quantity = taken / level.Price
+		}
+
+		estimate.Quantity += quantity
+		estimate.QuoteQty += quantity * level.Price
+		estimate.WorstPrice = level.Price
+		estimate.LevelsConsumed++
+
+		if remaining -= taken; remaining <= 0 {
+			estimate.Filled = true
+			break
+		}
+	}
+
+	estimate.AveragePrice = estimate.QuoteQty / estimate.Quantity
+	estimate.SlippageBps = utils.TruncateFloat(math.Abs(estimate.AveragePrice-estimate.BestPrice)/estimate.BestPrice*10000, 2)
+	estimate.ImpactBps = utils.TruncateFloat(math.Abs(estimate.AveragePrice-estimate.MidPrice)/estimate.MidPrice*10000, 2)
+	return
+}
+
+// checkSlippage refuses an order whose expected slippage is above the slippage.maxbps ceiling, or
+// that the book is too thin to fill. Markets without a local orderbook are not checked.
+func checkSlippage(pair, exchange, side string, quantity float64) error {
+	if utils.Config.Slippage.MaxBps <= 0 {
+		return nil
+	}
+
+	orderbookMutex.RLock()
+	orderbook := getOrderbook(pair, exchange)
+	orderbookMutex.RUnlock()
+
+	if orderbook.Pair == "" {
+		return nil
+	}
+
+	estimate, err := estimateSlippage(orderbook, side, quantity, false)
+	if err != nil {
+		return err
+	}
+
+	if !estimate.Filled {
+		return fmt.Errorf("Orderbook of %s is too thin to fill %v, only %v available over %d levels", pair, quantity, estimate.Quantity, estimate.LevelsConsumed)
+	}
+
+	if estimate.SlippageBps > utils.Config.Slippage.MaxBps {
+		return fmt.Errorf("Expected slippage of %v bps on %s is above the %v bps ceiling", estimate.SlippageBps, pair, utils.Config.Slippage.MaxBps)
+	}
+	return nil
+}
+
+func restHandlerSlippage(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	pair := strings.ToUpper(query.Get("pair"))
+	if pair == "" {
+		http.Error(httpRes, "Missing pair parameter", http.StatusBadRequest)
+		return
+	}
+
+	exchange := strings.ToLower(query.Get("exchange"))
+	if exchange == "" {
+		exchange = "binance"
+	}
+
+	var size float64
+	var quote bool
+	var err error
+	switch {
+	case query.Get("quantity") != "":
+		size, err = strconv.ParseFloat(query.Get("quantity"), 64)
+	case query.Get("quote") != "":
+		size, err = strconv.ParseFloat(query.Get("quote"), 64)
+		quote = true
+	default:
+		http.Error(httpRes, "Missing quantity or quote parameter", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(httpRes, "Invalid size parameter", http.StatusBadRequest)
+		return
+	}
+
+	orderbookMutex.RLock()
+	orderbook := getOrderbook(pair, exchange)
+	orderbookMutex.RUnlock()
+
+	if orderbook.Pair == "" {
+		http.Error(httpRes, fmt.Sprintf("No orderbook for %s on %s", pair, exchange), http.StatusNotFound)
+		return
+	}
+
+	estimate, err := estimateSlippage(orderbook, query.Get("side"), size, quote)
+	if err != nil {
+		http.Error(httpRes, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(estimate)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
@@ -71,9 +71,6 @@ func apiStrategyStopLossTakeProfit() {
 		var oldOrderList []models.Order
 		var oldPriceList []float64
 
-		// sellPercentDifference := utils.TruncateFloat(((orderBookAsksBaseTotal-orderBookBidsBaseTotal)/orderBookAsksBaseTotal)*100, 3)
-		// buyPercentDifference := utils.TruncateFloat(((orderBookBidsBaseTotal-orderBookAsksBaseTotal)/orderBookBidsBaseTotal)*100, 3)
-
 		analysis := getAnalysis(orderbookPair, orderbookExchange)
 		opportunity := analyseOpportunity(analysis, "15m", 0)
 
@@ -99,19 +96,21 @@ func apiStrategyStopLossTakeProfit() {
 				}
 
 				pairexchange := fmt.Sprintf("%s-%s", orderbookPair, orderbookExchange)
-				message = fmt.Sprintf("Price: %v | TP: %v | SL: %v",
-					price, opportunity.Takeprofit, opportunity.Stoploss)
+				message = fmt.Sprintf("Price: %v | TP: %v | SL: %v | Score: %v",
+					price, opportunity.Takeprofit, opportunity.Stoploss, opportunity.Score)
 
 				opportunityMutex.Lock()
 				if !strings.Contains(opportunityMap[pairexchange].Title, opportunity.Action) {
 					log.Printf("Opportunity: %s | %s \n", title, message)
 					opportunityMap[pairexchange] = notifications{
 						Title: title, Message: message,
+						Source: "strategy", Pair: opportunity.Pair,
 					}
 					wsBroadcastNotification <- opportunityMap[pairexchange]
 
 					//create opportunity record
 					opportunityModel := models.Opportunity{
+						Source:     models.OpportunitySourceAnalysis,
 						Trend:      analysis.Trend,
 						Pair:       opportunity.Pair,
 						Action:     opportunity.Action,
@@ -120,30 +119,29 @@ func apiStrategyStopLossTakeProfit() {
 						Exchange:   opportunity.Exchange,
 						Stoploss:   opportunity.Stoploss,
 						Takeprofit: opportunity.Takeprofit,
+						Score:      opportunity.Score,
 						Analysis:   opportunity.Analysis,
 					}
-					if err := utils.SqlDB.Create(&opportunityModel).Error; err != nil {
+					if isReplayMode() {
+						log.Printf("Replay: skipped saving opportunity %s %s \n", opportunity.Action, opportunity.Pair)
+					} else if err := utils.SqlDB.Create(&opportunityModel).Error; err != nil {
 						log.Println(err.Error())
+					} else {
+						go autoTradeOpportunity(opportunityModel, price)
 					}
 				}
 				opportunityMutex.Unlock()
 			}()
 		}
 
-		// if opportunity.Action == "BUY" {
-		// 	if buyPercentDifference < float64(3) { // || strings.Contains(analysis.Trend, "Bearish") {
-		// 		opportunity.Action = ""
-		// 	}
-		// }
-
-		// if opportunity.Action == "SELL" {
-		// 	if sellPercentDifference < float64(3) { // || strings.Contains(analysis.Trend, "Bullish") {
-		// 		opportunity.Action = ""
-		// 	}
-		// }
-
 		opportunityFound := opportunity.Action
 
+		// a halted or delisted market rejects orders, so its stoploss and takeprofit stay armed until
+		// it trades again
+		if market := getMarket(orderbookPair, orderbookExchange); !isMarketTradable(market) {
+			continue
+		}
+
 		//do a mutex RLock loop through orders
 		orderListMutex.RLock()
 		for _, oldOrder := range orderList {
@@ -244,9 +242,9 @@ func apiStrategyStopLossTakeProfit() {
 
 			switch newOrder.Exchange {
 			default:
-				binanceOrderCreate(newOrder.Pair, newOrder.Side, strconv.FormatFloat(newOrder.Price, 'f', -1, 64), strconv.FormatFloat(newOrder.Quantity, 'f', -1, 64), newOrder.Stoploss, newOrder.Takeprofit, newOrder.AutoRepeat, newOrder.RefOrderID)
+				binanceOrderCreate(newOrder.Pair, newOrder.Side, strconv.FormatFloat(newOrder.Price, 'f', -1, 64), strconv.FormatFloat(newOrder.Quantity, 'f', -1, 64), newOrder.Stoploss, newOrder.Takeprofit, newOrder.AutoRepeat, newOrder.RefOrderID, 0)
 			case "crex24":
-				crex24OrderCreate(newOrder.Pair, newOrder.Side, newOrder.Price, newOrder.Quantity, newOrder.Stoploss, newOrder.Takeprofit, newOrder.AutoRepeat, newOrder.RefOrderID)
+				crex24OrderCreate(newOrder.Pair, newOrder.Side, newOrder.Price, newOrder.Quantity, newOrder.Stoploss, newOrder.Takeprofit, newOrder.AutoRepeat, newOrder.RefOrderID, 0)
 			}
 		}
 
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"backpocket/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type largeTradeType struct {
+	Side                      string
+	Price, Quantity, QuoteQty float64
+	// Multiple is the size of the trade over the average trade of the window
+	Multiple  float64
+	TradeTime time.Time
+}
+
+// tradeBucketType aggregates the trades of one second, Buy and Sell being the taker side.
+type tradeBucketType struct {
+	Pair, Exchange string
+	Timestamp      time.Time
+
+	Open, High, Low, Close float64
+	Trades                 int
+
+	BuyVolume, SellVolume,
+	BuyQuote, SellQuote float64
+
+	// Delta is the taker buy less taker sell volume of the second and CVD its running total
+	Delta, CVD, VWAP float64
+
+	LargeTrades []largeTradeType
+}
+
+// tradeFlowType sums the buckets of a pair over the trades window.
+type tradeFlowType struct {
+	Pair, Exchange string
+	From, To       time.Time
+
+	Trades int
+	BuyVolume, SellVolume,
+	Delta, CVD, VWAP,
+	AverageTradeQuote float64
+
+	LargeTrades []largeTradeType
+	Buckets     []tradeBucketType
+}
+
+type tradeAggregatorType struct {
+	Current tradeBucketType
+	Buckets []tradeBucketType
+	CVD     float64
+
+	WindowTrades int
+	WindowQuote  float64
+}
+
+var (
+	tradeAggregators      = make(map[string]*tradeAggregatorType)
+	tradeAggregatorsMutex = sync.RWMutex{}
+)
+
+// averageTradeQuote is the average quote size of a trade over the closed buckets of the window.
+func (aggregator *tradeAggregatorType) averageTradeQuote() float64 {
+	if aggregator.WindowTrades == 0 {
+		return 0
+	}
+	return aggregator.WindowQuote / float64(aggregator.WindowTrades)
+}
+
+// closeBucket moves the current second into the window, drops the seconds that fell out of it and
+// publishes the closed bucket on the trades websocket.
+func (aggregator *tradeAggregatorType) closeBucket() {
+	bucket := aggregator.Current
+	aggregator.Current = tradeBucketType{}
+	if bucket.Trades == 0 {
+		return
+	}
+
+	if volume := bucket.BuyVolume + bucket.SellVolume; volume > 0 {
+		bucket.VWAP = utils.TruncateFloat((bucket.BuyQuote+bucket.SellQuote)/volume, 8)
+	}
+
+	aggregator.Buckets = append(aggregator.Buckets, bucket)
+	aggregator.WindowTrades += bucket.Trades
+	aggregator.WindowQuote += bucket.BuyQuote + bucket.SellQuote
+
+	for len(aggregator.Buckets) > 0 && bucket.Timestamp.Sub(aggregator.Buckets[0].Timestamp) > utils.Config.Trades.Window {
+		aggregator.WindowTrades -= aggregator.Buckets[0].Trades
+		aggregator.WindowQuote -= aggregator.Buckets[0].BuyQuote + aggregator.Buckets[0].SellQuote
+		aggregator.Buckets = aggregator.Buckets[1:]
+	}
+
+	select {
+	case wsBroadcastTrade <- bucket:
+	default:
+	}
+}
+
+// aggregateTrade adds an aggregated trade to the bucket of its second, buyerMaker marking a taker sell.
+// A trade is large when its quote size reaches trades.largequote, or when that is not set, the
+// trades.largemultiple of the average trade of the window.
+func aggregateTrade(pair, exchange string, price, quantity float64, buyerMaker bool, tradeTime time.Time) {
+	if pair == "" || price == 0 {
+		return
+	}
+
+	pairexchange := fmt.Sprintf("%s-%s", pair, strings.ToLower(exchange))
+	second := tradeTime.Truncate(time.Second)
+
+	tradeAggregatorsMutex.Lock()
+	defer tradeAggregatorsMutex.Unlock()
+
+	aggregator, found := tradeAggregators[pairexchange]
+	if !found {
+		aggregator = &tradeAggregatorType{}
+		tradeAggregators[pairexchange] = aggregator
+	}
+
+	if aggregator.Current.Trades > 0 && second.After(aggregator.Current.Timestamp) {
+		aggregator.closeBucket()
+	}
+
+	bucket := &aggregator.Current
+	if bucket.Trades == 0 {
+		*bucket = tradeBucketType{Pair: pair, Exchange: strings.ToLower(exchange), Timestamp: second, Open: price, High: price, Low: price}
+	}
+
+	bucket.Trades++
+	bucket.Close = price
+	if price > bucket.High {
+		bucket.High = price
+	}
+	if price < bucket.Low {
+		bucket.Low = price
+	}
+
+	quoteQty := price * quantity
+	side := "Buy"
+	if buyerMaker {
+		side = "Sell"
+		bucket.SellVolume += quantity
+		bucket.SellQuote += quoteQty
+		bucket.Delta -= quantity
+		aggregator.CVD -= quantity
+	} else {
+		bucket.BuyVolume += quantity
+		bucket.BuyQuote += quoteQty
+		bucket.Delta += quantity
+		aggregator.CVD += quantity
+	}
+	bucket.CVD = aggregator.CVD
+
+	averageQuote := aggregator.averageTradeQuote()
+	threshold := utils.Config.Trades.LargeQuote
+	if threshold <= 0 {
+		threshold = averageQuote * utils.Config.Trades.LargeMultiple
+	}
+
+	if threshold > 0 && quoteQty >= threshold {
+		largeTrade := largeTradeType{Side: side, Price: price, Quantity: quantity, QuoteQty: quoteQty, TradeTime: tradeTime}
+		if averageQuote > 0 {
+			largeTrade.Multiple = utils.TruncateFloat(quoteQty/averageQuote, 2)
+		}
+		bucket.LargeTrades = append(bucket.LargeTrades, largeTrade)
+	}
+}
+
+// flushTradeBuckets closes the buckets of the seconds that ended without a newer trade to close them.
+func flushTradeBuckets(now time.Time) {
+	second := now.Truncate(time.Second)
+
+	tradeAggregatorsMutex.Lock()
+	for _, aggregator := range tradeAggregators {
+		if aggregator.Current.Trades > 0 && aggregator.Current.Timestamp.Before(second) {
+			aggregator.closeBucket()
+		}
+	}
+	tradeAggregatorsMutex.Unlock()
+}
+
+// getTradeFlow sums the closed buckets of a pair over the trades window.
+func getTradeFlow(pair, exchange string) (flow tradeFlowType) {
+	tradeAggregatorsMutex.RLock()
+	defer tradeAggregatorsMutex.RUnlock()
+
+	aggregator, found := tradeAggregators[fmt.Sprintf("%s-%s", pair, strings.ToLower(exchange))]
+	if !found || len(aggregator.Buckets) == 0 {
+		return
+	}
+
+	flow.Pair = pair
+	flow.Exchange = strings.ToLower(exchange)
+	flow.From = aggregator.Buckets[0].Timestamp
+	flow.To = aggregator.Buckets[len(aggregator.Buckets)-1].Timestamp
+	flow.CVD = aggregator.Buckets[len(aggregator.Buckets)-1].CVD
+	flow.Buckets = append(flow.Buckets, aggregator.Buckets...)
+
+	var quote float64
+	for _, bucket := range aggregator.Buckets {
+		flow.Trades += bucket.Trades
+		flow.BuyVolume += bucket.BuyVolume
+		flow.SellVolume += bucket.SellVolume
+		quote += bucket.BuyQuote + bucket.SellQuote
+		flow.LargeTrades = append(flow.LargeTrades, bucket.LargeTrades...)
+	}
+
+	flow.Delta = flow.BuyVolume - flow.SellVolume
+	if volume := flow.BuyVolume + flow.SellVolume; volume > 0 {
+		flow.VWAP = utils.TruncateFloat(quote/volume, 8)
+	}
+	flow.AverageTradeQuote = utils.TruncateFloat(aggregator.averageTradeQuote(), 8)
+	return
+}
+
+func restHandlerTrades(httpRes http.ResponseWriter, httpReq *http.Request) {
+	query := httpReq.URL.Query()
+
+	pair := strings.ToUpper(query.Get("pair"))
+	if pair == "" {
+		http.Error(httpRes, "Missing pair parameter", http.StatusBadRequest)
+		return
+	}
+
+	exchange := strings.ToLower(query.Get("exchange"))
+	if exchange == "" {
+		exchange = "binance"
+	}
+
+	flow := getTradeFlow(pair, exchange)
+	if flow.Pair == "" {
+		http.Error(httpRes, fmt.Sprintf("No trades for %s on %s", pair, exchange), http.StatusNotFound)
+		return
+	}
+
+	httpRes.Header().Set("Content-Type", "application/json")
+	jsonResponse, err := json.Marshal(flow)
+	if err != nil {
+		http.Error(httpRes, "Error converting to JSON", http.StatusInternalServerError)
+		return
+	}
+
+	httpRes.Write(jsonResponse)
+}
@@ -1,22 +1,18 @@
 package main
 
 import (
+	"backpocket/models"
 	"fmt"
 	"net/http"
-	"sort"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 var (
 	tradeList = make(map[string][]trades)
 
-	tradeListMutex    = sync.RWMutex{}
-	wsConnTradesMutex = sync.RWMutex{}
+	tradeListMutex = sync.RWMutex{}
 
-	wsConnTrades     = make(map[*websocket.Conn]bool)
 	wsBroadcastTrade = make(chan interface{}, 102400)
 )
 
@@ -36,126 +32,76 @@ type trades struct {
 func wsHandlerTrades(httpRes http.ResponseWriter, httpReq *http.Request) {
 	if wsConn := wsHandleConnections(httpRes, httpReq); wsConn != nil {
 
-		wsConn.SetPongHandler(func(string) error {
-			wsConn.SetReadDeadline(time.Now().Add(pongWait))
-			return nil
-		})
-
-		//check for enabled markets
-		enabledMarketList := make(map[string]bool)
-		marketListMutex.RLock()
-		for _, market := range marketList {
-			if market.Status == "enabled" {
-				enabledMarketList[fmt.Sprintf("%s-%s", market.Pair, market.Exchange)] = true
+		// clients get the per second aggregates rather than raw prints, starting with the recent flow
+		client := wsHubTrades.register(wsConn, httpReq, sendTradeFlows)
+		defer client.close()
+
+		for {
+			var msg wsSubscriptionMsgType
+			if err := wsConn.ReadJSON(&msg); err != nil {
+				return
 			}
-		}
-		marketListMutex.RUnlock()
-		//check for enabled markets
 
-		var unlockedTradeList []trades
-		tradeListMutex.RLock()
-		for marketTradeKey := range enabledMarketList {
-			unlockedTradeList = append(unlockedTradeList, tradeList[marketTradeKey]...)
+			client.handle(msg)
 		}
-		tradeListMutex.RUnlock()
+	}
+}
 
-		sort.SliceStable(unlockedTradeList, func(i, j int) bool {
-			return unlockedTradeList[i].TradeID < unlockedTradeList[j].TradeID
-		})
+// sendTradeFlows queues for a client the last minute of flow of the enabled markets subscriptions match.
+func sendTradeFlows(client *wsClientType, subscriptions *wsSubscriptionsType) {
+	//check for enabled markets
+	enabledMarketList := make(map[string]models.Market)
+	marketListMutex.RLock()
+	for _, market := range marketList {
+		if market.Status == "enabled" && subscriptions.matches(market.Pair, market.Exchange, "", "flow") {
+			enabledMarketList[fmt.Sprintf("%s-%s", market.Pair, market.Exchange)] = market
+		}
+	}
+	marketListMutex.RUnlock()
+	//check for enabled markets
 
-		for _, trade := range unlockedTradeList {
-			wsConn.WriteJSON(trade)
+	for _, market := range enabledMarketList {
+		flow := getTradeFlow(market.Pair, market.Exchange)
+		if flow.Pair == "" {
+			continue
 		}
 
-		wsConnTradesMutex.Lock()
-		wsConnTrades[wsConn] = true
-		wsConnTradesMutex.Unlock()
+		if len(flow.Buckets) > 60 {
+			flow.Buckets = flow.Buckets[len(flow.Buckets)-60:]
+		}
+		client.send("tradeflow", "", flow)
 	}
 }
 
 func wsHandlerTradeBroadcast() {
 
-	// loop through enabled markets and send updates down
+	// close the buckets of the seconds gone by, publishing them to the clients
 	go func() {
-		limiter := time.Tick(time.Minute)
-		var enabledMarketList map[string]bool
-
-		// tickerMarkets := time.Tick(time.Minute * 3)
-		// //check for enabled markets
-		// marketListMutex.RLock()
-		// for _, market := range marketList {
-		// 	if market.Status == "enabled" {
-		// 		enabledMarketList[fmt.Sprintf("%s-%s", market.Pair, market.Exchange)] = true
-		// 	}
-		// }
-		// marketListMutex.RUnlock()
-		// //check for enabled markets
-
-		for {
-			<-limiter
-
-			//check for enabled markets
-			enabledMarketList = make(map[string]bool)
-			marketListMutex.RLock()
-			for _, market := range marketList {
-				if market.Status == "enabled" {
-					enabledMarketList[fmt.Sprintf("%s-%s", market.Pair, market.Exchange)] = true
-				}
-			}
-			marketListMutex.RUnlock()
-
-			var unlockedTradeList []trades
-			tradeListMutex.RLock()
-			for marketTradeKey := range enabledMarketList {
-				unlockedTradeList = append(unlockedTradeList, tradeList[marketTradeKey]...)
-			}
-			tradeListMutex.RUnlock()
-
-			sort.SliceStable(unlockedTradeList, func(i, j int) bool {
-				return unlockedTradeList[i].TradeID < unlockedTradeList[j].TradeID
-			})
-
-			for _, trade := range unlockedTradeList {
-				if trade.IsSent {
-					continue
-				}
-				select {
-				case wsBroadcastTrade <- trade:
-				default:
-				}
-				trade.IsSent = true
-				updateTrade(trade)
-			}
-		}
-	}()
-	// loop through enabled markets and send updates down
-
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
+		ticker := time.NewTicker(time.Second)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			wsConnTradesMutex.Lock()
-			for wsConn := range wsConnTrades {
-				if err := wsConn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					delete(wsConnTrades, wsConn)
-					wsConn.Close()
-				}
-			}
-			wsConnTradesMutex.Unlock()
+		for now := range ticker.C {
+			flushTradeBuckets(now)
 		}
 	}()
 
 	go func() {
 		for trade := range wsBroadcastTrade {
-			wsConnTradesMutex.Lock()
-			for wsConn := range wsConnTrades {
-				if err := wsConn.WriteJSON(trade); err != nil {
-					delete(wsConnTrades, wsConn)
-					wsConn.Close()
+			var pair, exchange string
+			var events []string
+			msgType := "trade"
+			if bucket, ok := trade.(tradeBucketType); ok {
+				msgType = "tradebucket"
+				pair, exchange = bucket.Pair, bucket.Exchange
+				events = append(events, "bucket")
+				if len(bucket.LargeTrades) > 0 {
+					events = append(events, "largetrade")
 				}
 			}
-			wsConnTradesMutex.Unlock()
+
+			wsHubTrades.broadcast(msgType, "", trade, func(subscriptions *wsSubscriptionsType) (interface{}, bool) {
+				return trade, subscriptions.matches(pair, exchange, "", events...)
+			})
 		}
 	}()
 }
@@ -150,6 +150,32 @@ type binanceStreamBookDepthResp struct {
 	// 	BestAskQty   string `json:"A"`
 	// } `json:"data,omitempty"`
 }
+
+// binanceStreamDepthDiffResp is a @depth@100ms diff event, U and u being the first and last update ids it covers
+type binanceStreamDepthDiffResp struct {
+	ID     uint     `json:"id,omitempty"`
+	Result []string `json:"result,omitempty"`
+
+	Stream string `json:"stream,omitempty"`
+	Data   struct {
+		Event         string `json:"e"`
+		EventTime     uint64 `json:"E"`
+		Symbol        string `json:"s"`
+		FirstUpdateID uint64 `json:"U"`
+		FinalUpdateID uint64 `json:"u"`
+
+		Bids [][]string `json:"b"`
+		Asks [][]string `json:"a"`
+	} `json:"data,omitempty"`
+}
+
+type binanceDepthSnapshotResp struct {
+	LastUpdateID uint64 `json:"lastUpdateId"`
+
+	Bids [][]string `json:"bids"`
+	Asks [][]string `json:"asks"`
+}
+
 type binanceStreamAssetResp struct {
 	Stream string `json:"stream,omitempty"`
 	Data   struct {
@@ -245,6 +271,7 @@ func binanceCheckError(respBytes []byte) {
 	if binanceError.Msg != "" {
 		wsBroadcastNotification <- notifications{
 			Type: "info", Title: "*Binance Exchange*", Message: binanceError.Msg,
+			Severity: "error", Source: "binance",
 		}
 	}
 }
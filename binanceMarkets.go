@@ -32,9 +32,10 @@ func binanceGetExistingMarkets(wg *sync.WaitGroup) {
 			time.Sleep(time.Minute * 5)
 			continue
 		}
-		defer httpResponse.Body.Close()
 
+		// closed on every pass, the loop never returning to run a deferred close
 		bodyBytes, err := io.ReadAll(httpResponse.Body)
+		httpResponse.Body.Close()
 		if err != nil {
 			log.Printf(err.Error())
 			time.Sleep(time.Minute * 5)
@@ -43,7 +44,7 @@ func binanceGetExistingMarkets(wg *sync.WaitGroup) {
 
 		var exchangeInfo struct {
 			Symbols []struct {
-				Symbol, BaseAsset,
+				Symbol, Status, BaseAsset,
 				QuoteAsset string
 				Filters []struct {
 					FilterType, MinQty, MaxQty,
@@ -55,15 +56,26 @@ func binanceGetExistingMarkets(wg *sync.WaitGroup) {
 		}
 
 		if err := json.Unmarshal(bodyBytes, &exchangeInfo); err != nil {
-			log.Panic(string(bodyBytes))
-			log.Panic(err.Error())
-			return
+			// a rate limit or maintenance page is retried rather than ending the process
+			log.Printf("exchangeInfo: %v %s \n", err, string(bodyBytes))
+			time.Sleep(time.Minute * 5)
+			continue
 		}
 
+		if len(exchangeInfo.Symbols) == 0 {
+			log.Println("exchangeInfo returned no symbols")
+			time.Sleep(time.Minute * 5)
+			continue
+		}
+
+		listedSymbols := make(map[string]bool)
 		newBatchedMarkets := []models.Market{}
 		for _, marketPair := range exchangeInfo.Symbols {
+			listedSymbols[marketPair.Symbol] = true
 
 			market := getMarket(marketPair.Symbol, "binance")
+			oldTradingStatus := market.TradingStatus
+			market.TradingStatus = marketPair.Status
 
 			//this logic adds a new marketpair
 			market.Pair = marketPair.Symbol
@@ -123,10 +135,32 @@ func binanceGetExistingMarkets(wg *sync.WaitGroup) {
 				market.Createdate = time.Now()
 				market.Updatedate = time.Now()
 				newBatchedMarkets = append(newBatchedMarkets, market)
+				if !lFirstRun {
+					binanceNotifyListing(market)
+				}
+			} else if oldTradingStatus != market.TradingStatus {
+				binanceNotifyTradingStatus(market, oldTradingStatus)
 			}
 			updateMarket(market)
 		}
 
+		//markets no longer in exchangeInfo have been delisted
+		var delistedMarkets []models.Market
+		marketListMutex.RLock()
+		for _, market := range marketList {
+			if market.Exchange == "binance" && !listedSymbols[market.Pair] && market.TradingStatus != MarketDelisted {
+				delistedMarkets = append(delistedMarkets, market)
+			}
+		}
+		marketListMutex.RUnlock()
+
+		for _, market := range delistedMarkets {
+			oldTradingStatus := market.TradingStatus
+			market.TradingStatus = MarketDelisted
+			updateMarket(market)
+			binanceNotifyTradingStatus(market, oldTradingStatus)
+		}
+
 		if len(newBatchedMarkets) > 0 {
 			if err := utils.SqlDB.Transaction(func(tx *gorm.DB) error {
 				if err := tx.CreateInBatches(newBatchedMarkets, 500).Error; err != nil {
@@ -144,7 +178,46 @@ func binanceGetExistingMarkets(wg *sync.WaitGroup) {
 			log.Println("Markets First Run Completed")
 		}
 
-		time.Sleep(time.Hour * 6)
+		// a replay runs on the markets known at start, without polling the listings
+		if isReplayMode() {
+			return
+		}
+
+		time.Sleep(utils.Config.Listings.Interval)
+	}
+}
+
+// binanceNotifyListing raises an alert when a newly listed market trades a watched base asset.
+func binanceNotifyListing(market models.Market) {
+	log.Printf("New listing: %s (%s/%s) \n", market.Pair, market.BaseAsset, market.QuoteAsset)
+
+	for _, baseAsset := range utils.Config.Listings.WatchedBaseAssets {
+		if strings.EqualFold(baseAsset, market.BaseAsset) {
+			wsBroadcastNotification <- notifications{
+				Type: "listing", Title: "*Binance Exchange* new listing *" + market.Pair + "*",
+				Message: fmt.Sprintf("%s is now listed against %s with status %s", market.BaseAsset, market.QuoteAsset, market.TradingStatus),
+			}
+			return
+		}
+	}
+}
+
+// binanceNotifyTradingStatus saves a changed symbol status and publishes it; halted and delisted
+// markets are no longer traded by the strategies.
+func binanceNotifyTradingStatus(market models.Market, oldTradingStatus string) {
+	if err := utils.SqlDB.Model(&market).Where("pair = ? and exchange = ?", market.Pair, market.Exchange).Updates(
+		map[string]interface{}{"tradingstatus": market.TradingStatus}).Error; err != nil {
+		log.Println(err.Error())
+	}
+
+	if oldTradingStatus == "" {
+		return
+	}
+
+	wsBroadcastMarket <- market
+	wsBroadcastNotification <- notifications{
+		Type: "listing", Title: "*Binance Exchange* *" + market.Pair + "* " + strings.ToLower(market.TradingStatus),
+		Message: fmt.Sprintf("%s status changed from %s to %s", market.Pair, oldTradingStatus, market.TradingStatus),
 	}
 }
 
@@ -175,6 +248,7 @@ func binanceMarket24hrTicker() {
 			LowPrice, OpenPrice,
 			AskPrice, AskQty,
 			BidPrice, BidQty,
+			Volume, QuoteVolume,
 			PrevClosePrice string
 			Count int
 		}
@@ -211,6 +285,20 @@ func binanceMarket24hrTicker() {
 					log.Println(err.Error())
 				}
 
+				askPrice, _ := strconv.ParseFloat(marketPair.AskPrice, 64)
+				bidPrice, _ := strconv.ParseFloat(marketPair.BidPrice, 64)
+				if bidPrice > 0 && askPrice > bidPrice {
+					market.Spread = utils.TruncateFloat((askPrice-bidPrice)/bidPrice*100, 4)
+				}
+
+				if market.Volume24h, err = strconv.ParseFloat(marketPair.Volume, 64); err != nil {
+					log.Println(err.Error())
+				}
+
+				if market.VolumeQuote24h, err = strconv.ParseFloat(marketPair.QuoteVolume, 64); err != nil {
+					log.Println(err.Error())
+				}
+
 				if market.Status != "enabled" {
 					if market.Open, err = strconv.ParseFloat(marketPair.OpenPrice, 64); err != nil {
 						log.Println(err.Error())
@@ -220,6 +308,14 @@ func binanceMarket24hrTicker() {
 						log.Println(err.Error())
 					}
 					market.NumOfTrades = marketPair.Count
+
+					if market.Volume, err = strconv.ParseFloat(marketPair.Volume, 64); err != nil {
+						log.Println(err.Error())
+					}
+
+					if market.VolumeQuote, err = strconv.ParseFloat(marketPair.QuoteVolume, 64); err != nil {
+						log.Println(err.Error())
+					}
 				}
 				market.LastPrice = market.Price
 				market.Price = market.Close
@@ -232,7 +328,7 @@ func binanceMarket24hrTicker() {
 		if len(updateBatchedMarkets) > 0 {
 			values := make([]clause.Expr, 0, len(updateBatchedMarkets))
 			for _, market := range updateBatchedMarkets {
-				values = append(values, gorm.Expr("(?::bigint, ?::integer, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision) ", market.ID, market.NumOfTrades, market.Open, market.Close, market.High, market.Low, market.Volume, market.VolumeQuote, market.LastPrice, market.Price, market.UpperBand, market.MiddleBand, market.LowerBand, market.PriceChange, market.PriceChangePercent, market.HighPrice, market.LowPrice))
+				values = append(values, gorm.Expr("(?::bigint, ?::integer, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision, ?::double precision) ", market.ID, market.NumOfTrades, market.Open, market.Close, market.High, market.Low, market.Volume, market.VolumeQuote, market.LastPrice, market.Price, market.UpperBand, market.MiddleBand, market.LowerBand, market.PriceChange, market.PriceChangePercent, market.HighPrice, market.LowPrice, market.Volume24h, market.VolumeQuote24h))
 			}
 
 			batchedValues := make([]clause.Expr, 0, 500)
@@ -258,7 +354,7 @@ func batchedUpdateQueryMarkets(batchedValues []clause.Expr) {
 	valuesExpr.WithoutParentheses = true
 
 	if tx := utils.SqlDB.Exec(
-		"UPDATE markets SET numoftrades = tmp.numoftrades, open = tmp.open, close = tmp.close, high = tmp.high, low = tmp.low, volume = tmp.volume, volumequote = tmp.volumequote, lastprice = tmp.lastprice, price = tmp.price, upperband = tmp.upperband, middleband = tmp.middleband, lowerband = tmp.lowerband, pricechange = tmp.pricechange, pricechangepercent = tmp.pricechangepercent, highprice = tmp.highprice, lowprice = tmp.lowprice, updatedate = NOW() FROM (VALUES ?) tmp(id, numoftrades, open, close, high, low, volume, volumequote, lastprice, price, upperband, middleband, lowerband, pricechange, pricechangepercent, highprice, lowprice) WHERE markets.id = tmp.id",
+		"UPDATE markets SET numoftrades = tmp.numoftrades, open = tmp.open, close = tmp.close, high = tmp.high, low = tmp.low, volume = tmp.volume, volumequote = tmp.volumequote, lastprice = tmp.lastprice, price = tmp.price, upperband = tmp.upperband, middleband = tmp.middleband, lowerband = tmp.lowerband, pricechange = tmp.pricechange, pricechangepercent = tmp.pricechangepercent, highprice = tmp.highprice, lowprice = tmp.lowprice, volume24h = tmp.volume24h, volumequote24h = tmp.volumequote24h, updatedate = NOW() FROM (VALUES ?) tmp(id, numoftrades, open, close, high, low, volume, volumequote, lastprice, price, upperband, middleband, lowerband, pricechange, pricechangepercent, highprice, lowprice, volume24h, volumequote24h) WHERE markets.id = tmp.id",
 		valuesExpr,
 	); tx.Error != nil {
 		log.Printf("Error Creating Batches: %+v \n", tx.Error)
@@ -369,6 +465,12 @@ func binanceMarketOHLCVStream() {
 		marketRSIPricesMutex.Unlock()
 		calculateRSIBands(&market)
 		updateMarket(market)
+		recordEvent(recordType{Kind: RecordMarket, Market: &market})
+
+		select {
+		case chanAlertMarkets <- market:
+		default:
+		}
 
 		if wsResp.Data.Kline.Closed {
 			if err := utils.SqlDB.Model(&market).Where("pair = ? and exchange = ?", market.Pair, market.Exchange).Updates(&market).Error; err != nil {
@@ -3,28 +3,245 @@ package main
 import (
 	"backpocket/utils"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 var (
 	chanRestartBinanceOrderBookStream = make(chan bool, 10)
+
+	binanceDepthBooks      = make(map[string]*binanceDepthBook)
+	binanceDepthBooksMutex = sync.Mutex{}
+
+	// binanceDepthSnapshotMutex fetches one REST snapshot at a time, paced to orderbook.snapshotweight,
+	// so a resync of every market after a reconnect does not burst the request weight limit
+	binanceDepthSnapshotMutex = sync.Mutex{}
+
+	// binanceDepthSnapshotLimits are the limits /depth accepts, with the request weight of each
+	binanceDepthSnapshotLimits = []struct{ Limit, Weight int }{
+		{100, 5}, {500, 25}, {1000, 50}, {5000, 250},
+	}
 )
 
-func binanceOrderBookStream() {
-	log.Println("Connecting binanceOrderBookStream")
-	var streamParams []string
+// binanceDepthBook is the local order book of a pair, built from a REST snapshot and kept current
+// by the @depth@100ms diff events that follow its LastUpdateID.
+type binanceDepthBook struct {
+	LastUpdateID uint64
+	Synced       bool
+	Syncing      bool
+
+	Bids, Asks map[float64]float64
+
+	// Buffer holds the diff events received while the snapshot is being fetched
+	Buffer    []binanceStreamDepthDiffResp
+	Published time.Time
+}
+
+func binanceOrderBookStreamParams() (streamParams []string) {
 	marketListMutex.RLock()
 	for _, market := range marketList {
 		if market.Status == "enabled" && market.Exchange == "binance" {
-			streamParams = append(streamParams, strings.ToLower(market.Pair)+"@depth20@1000ms")
+			streamParams = append(streamParams, strings.ToLower(market.Pair)+"@depth@100ms")
 		}
 	}
 	marketListMutex.RUnlock()
+	return
+}
+
+func binanceDepthSnapshot(pair string, limit int) (snapshot binanceDepthSnapshotResp, err error) {
+	httpClient := http.Client{Timeout: time.Duration(time.Second * 30)}
+	httpResponse, err := httpClient.Get(fmt.Sprintf("%s/depth?symbol=%s&limit=%d", binanceRestURL, pair, limit))
+	if err != nil {
+		return
+	}
+	defer httpResponse.Body.Close()
 
-	wsResp := binanceStreamBookDepthResp{}
+	bodyBytes, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return
+	}
+
+	if httpResponse.StatusCode != http.StatusOK {
+		binanceCheckError(bodyBytes)
+		err = fmt.Errorf("depth snapshot of %s responded with %s", pair, httpResponse.Status)
+		return
+	}
+
+	err = json.Unmarshal(bodyBytes, &snapshot)
+	return
+}
+
+// binanceDepthSnapshotLimit returns the smallest snapshot limit holding depth levels, at least the
+// 100 that cost no more than fewer, and the request weight it uses.
+func binanceDepthSnapshotLimit(depth int) (limit, weight int) {
+	for _, snapshotLimit := range binanceDepthSnapshotLimits {
+		limit, weight = snapshotLimit.Limit, snapshotLimit.Weight
+		if depth <= limit {
+			return
+		}
+	}
+	return
+}
+
+// applyLevels sets the quantity of each [price, quantity] level, a zero quantity removing the level.
+func applyLevels(side map[float64]float64, levels [][]string) {
+	for _, level := range levels {
+		if len(level) < 2 {
+			continue
+		}
+
+		price, _ := strconv.ParseFloat(level[0], 64)
+		quantity, _ := strconv.ParseFloat(level[1], 64)
+		if quantity == 0 {
+			delete(side, price)
+		} else {
+			side[price] = quantity
+		}
+	}
+}
+
+// applyDiff applies a diff event on top of the book and reports false when the event does not
+// follow on from LastUpdateID, meaning updates were missed and the book must be resynced.
+// Events already covered by the book are dropped.
+func (book *binanceDepthBook) applyDiff(diff binanceStreamDepthDiffResp) bool {
+	if diff.Data.FinalUpdateID <= book.LastUpdateID {
+		return true
+	}
+
+	if diff.Data.FirstUpdateID > book.LastUpdateID+1 {
+		return false
+	}
+
+	applyLevels(book.Bids, diff.Data.Bids)
+	applyLevels(book.Asks, diff.Data.Asks)
+	book.LastUpdateID = diff.Data.FinalUpdateID
+	return true
+}
+
+// binanceResyncDepthBook loads a fresh snapshot of the pair and replays the buffered diff events on
+// it, retrying until the buffered events line up with a snapshot.
+func binanceResyncDepthBook(pair string) {
+	for {
+		limit, weight := binanceDepthSnapshotLimit(utils.Config.Orderbook.Depth)
+
+		binanceDepthSnapshotMutex.Lock()
+		snapshot, err := binanceDepthSnapshot(pair, limit)
+		if utils.Config.Orderbook.SnapshotWeight > 0 {
+			time.Sleep(time.Minute * time.Duration(weight) / time.Duration(utils.Config.Orderbook.SnapshotWeight))
+		}
+		binanceDepthSnapshotMutex.Unlock()
+
+		if err != nil {
+			log.Printf("binanceResyncDepthBook %s: %v \n", pair, err)
+			time.Sleep(time.Second * 10)
+			continue
+		}
+
+		binanceDepthBooksMutex.Lock()
+		book, found := binanceDepthBooks[pair]
+		if !found {
+			binanceDepthBooksMutex.Unlock()
+			return
+		}
+
+		book.LastUpdateID = snapshot.LastUpdateID
+		book.Bids = make(map[float64]float64)
+		book.Asks = make(map[float64]float64)
+		applyLevels(book.Bids, snapshot.Bids)
+		applyLevels(book.Asks, snapshot.Asks)
+
+		book.Synced = true
+		for _, diff := range book.Buffer {
+			if book.Synced = book.applyDiff(diff); !book.Synced {
+				break
+			}
+		}
+		book.Buffer = nil
+
+		if book.Synced {
+			book.Syncing = false
+			binanceDepthBooksMutex.Unlock()
+			return
+		}
+		binanceDepthBooksMutex.Unlock()
+
+		log.Printf("binanceResyncDepthBook %s: snapshot %d is behind the buffered updates, retrying \n", pair, snapshot.LastUpdateID)
+		time.Sleep(time.Second)
+	}
+}
+
+// binanceResetDepthBooks drops every local book after a reconnect of the stream, leaving the books
+// being resynced to pick up the events of the new connection.
+func binanceResetDepthBooks() {
+	binanceDepthBooksMutex.Lock()
+	for pair, book := range binanceDepthBooks {
+		if book.Syncing {
+			book.Buffer = nil
+		} else {
+			delete(binanceDepthBooks, pair)
+		}
+	}
+	binanceDepthBooksMutex.Unlock()
+}
+
+// binanceUpdateDepthBook applies a diff event to the local book of its pair and reports whether the
+// book is in sync and due to be published.
+func binanceUpdateDepthBook(pair string, diff binanceStreamDepthDiffResp) (bids, asks map[float64]float64, publish bool) {
+	binanceDepthBooksMutex.Lock()
+	defer binanceDepthBooksMutex.Unlock()
+
+	book, found := binanceDepthBooks[pair]
+	if !found {
+		book = &binanceDepthBook{}
+		binanceDepthBooks[pair] = book
+	}
+
+	if !book.Synced {
+		book.Buffer = append(book.Buffer, diff)
+		if !book.Syncing {
+			book.Syncing = true
+			go binanceResyncDepthBook(pair)
+		}
+		return
+	}
+
+	if !book.applyDiff(diff) {
+		log.Printf("binanceOrderBookStream %s: missed updates %d to %d, resyncing \n", pair, book.LastUpdateID+1, diff.Data.FirstUpdateID-1)
+		book.Synced = false
+		book.Syncing = true
+		book.Buffer = []binanceStreamDepthDiffResp{diff}
+		go binanceResyncDepthBook(pair)
+		return
+	}
+
+	if time.Since(book.Published) < utils.Config.Orderbook.Interval {
+		return
+	}
+	book.Published = time.Now()
+
+	bids = make(map[float64]float64, len(book.Bids))
+	for price, quantity := range book.Bids {
+		bids[price] = quantity
+	}
+
+	asks = make(map[float64]float64, len(book.Asks))
+	for price, quantity := range book.Asks {
+		asks[price] = quantity
+	}
+	return bids, asks, true
+}
+
+func binanceOrderBookStream() {
+	log.Println("Connecting binanceOrderBookStream")
+	streamParams := binanceOrderBookStreamParams()
+
+	wsResp := binanceStreamDepthDiffResp{}
 	bwConn := binanceWSConnect(streamParams)
 	if _, _, err := bwConn.ReadMessage(); err != nil {
 		log.Println("err ", err.Error())
@@ -36,16 +253,10 @@ func binanceOrderBookStream() {
 
 		select {
 		case <-chanRestartBinanceOrderBookStream:
-			streamParams = nil
-			marketListMutex.RLock()
-			for _, market := range marketList {
-				if market.Status == "enabled" && market.Exchange == "binance" {
-					streamParams = append(streamParams, strings.ToLower(market.Pair)+"@depth20@1000ms")
-				}
-			}
-			marketListMutex.RUnlock()
+			streamParams = binanceOrderBookStreamParams()
 
 			bwConn.Close()
+			binanceResetDepthBooks()
 			bwConn = binanceWSConnect(streamParams)
 			if _, _, err := bwConn.ReadMessage(); err != nil {
 				log.Println("err ", err.Error())
@@ -56,6 +267,7 @@ func binanceOrderBookStream() {
 		}
 
 		_, wsRespBytes, _ := bwConn.ReadMessage()
+		wsResp = binanceStreamDepthDiffResp{}
 		if err := json.Unmarshal(wsRespBytes, &wsResp); err != nil {
 			// if err := bwConn.ReadJSON(&wsResp); err != nil {
 			log.Println("binanceOrderBookStream bwCon read error:", err)
@@ -74,6 +286,11 @@ func binanceOrderBookStream() {
 			continue
 		}
 
+		bids, asks, publish := binanceUpdateDepthBook(marketPair, wsResp)
+		if !publish {
+			continue
+		}
+
 		market := getMarket(marketPair, "binance")
 		orderbook := getOrderbook(marketPair, "binance")
 
@@ -87,57 +304,30 @@ func binanceOrderBookStream() {
 		}
 
 		if orderbook.Pair == "" {
+			orderbookMutex.Unlock()
 			continue
 		}
 
-		orderbook.Bids = nil
-		var prevBidTotal float64
-		var prevBidQuoteTotal float64
-		for _, bid := range wsResp.Data.Bids {
-			price, _ := strconv.ParseFloat(bid[0], 64)
-			quantity, _ := strconv.ParseFloat(bid[1], 64)
-			quoteQty := quantity * price
-
-			orderbook.Bids = append(orderbook.Bids, bidAskStruct{Price: price, Quantity: quantity, Total: prevBidTotal + quantity, QuoteQty: quoteQty, QuoteTotal: prevBidQuoteTotal + quoteQty})
-			prevBidTotal += quantity
-			prevBidQuoteTotal += quoteQty
-		}
-		orderbook.BidsBaseTotal = prevBidTotal
-		orderbook.BidsQuoteTotal = prevBidQuoteTotal
-		// bidsQuoteAverage := orderbook.BidsQuoteTotal / float64(len(orderbook.Bids))
-		for id := range orderbook.Bids {
-			orderbook.Bids[id].Percentage = utils.TruncateFloat((orderbook.Bids[id].Total/orderbook.BidsBaseTotal)*100.00, 3)
-		}
-
-		//
-
-		orderbook.Asks = nil
-		var prevAskTotal float64
-		var prevAskQuoteTotal float64
-		for _, ask := range wsResp.Data.Asks {
-			price, _ := strconv.ParseFloat(ask[0], 64)
-			quantity, _ := strconv.ParseFloat(ask[1], 64)
-			quoteQty := quantity * price
-
-			orderbook.Asks = append(orderbook.Asks, bidAskStruct{Price: price, Quantity: quantity, Total: prevAskTotal + quantity, QuoteQty: quoteQty, QuoteTotal: prevAskQuoteTotal + quoteQty})
-			prevAskTotal += quantity
-			prevAskQuoteTotal += quoteQty
-		}
-		orderbook.AsksBaseTotal = prevAskTotal
-		orderbook.AsksQuoteTotal = prevAskQuoteTotal
-		// asksQuoteAverage := orderbook.AsksQuoteTotal / float64(len(orderbook.Asks))
-		for id := range orderbook.Asks {
-			orderbook.Asks[id].Percentage = utils.TruncateFloat((orderbook.Asks[id].Total/orderbook.AsksBaseTotal)*100.00, 3)
-		}
+		orderbook.Bids, orderbook.BidsBaseTotal, orderbook.BidsQuoteTotal = orderbookLevels(bids, true, utils.Config.Orderbook.Depth)
+		orderbook.Asks, orderbook.AsksBaseTotal, orderbook.AsksQuoteTotal = orderbookLevels(asks, false, utils.Config.Orderbook.Depth)
 
 		updateOrderbook(orderbook)
 		orderbookMutex.Unlock()
+		recordEvent(recordType{Kind: RecordOrderbook, Orderbook: &orderbook})
 
 		select {
 		case wsBroadcastMarket <- market:
 		default:
 		}
 
+		//only the kline stream reports closed candles to the alert volume history
+		alertMarket := market
+		alertMarket.Closed = 0
+		select {
+		case chanAlertMarkets <- alertMarket:
+		default:
+		}
+
 		// select {
 		// case wsBroadcastOrderBook <- orderbook:
 		// default:
@@ -148,6 +338,11 @@ func binanceOrderBookStream() {
 		default:
 		}
 
+		select {
+		case chanOrderbookAnalytics <- orderbook:
+		default:
+		}
+
 	}
 	//loop through and read all messages received
 }
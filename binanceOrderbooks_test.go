@@ -0,0 +1,67 @@
+package main
+
+import "testing"
+
+func depthDiff(first, final uint64, bids, asks [][]string) (diff binanceStreamDepthDiffResp) {
+	diff.Data.FirstUpdateID = first
+	diff.Data.FinalUpdateID = final
+	diff.Data.Bids = bids
+	diff.Data.Asks = asks
+	return
+}
+
+func TestBinanceDepthBookApplyDiff(t *testing.T) {
+	book := &binanceDepthBook{
+		LastUpdateID: 100,
+		Bids:         map[float64]float64{10: 1, 9: 2},
+		Asks:         map[float64]float64{11: 1, 12: 2},
+	}
+
+	// an event the snapshot already covers is dropped without touching the book
+	if !book.applyDiff(depthDiff(90, 100, [][]string{{"10", "5"}}, nil)) {
+		t.Fatal("stale event reported a gap")
+	}
+	if book.Bids[10] != 1 || book.LastUpdateID != 100 {
+		t.Fatalf("stale event was applied: %v %d", book.Bids, book.LastUpdateID)
+	}
+
+	// the first event after a snapshot straddles its LastUpdateID
+	if !book.applyDiff(depthDiff(95, 105, [][]string{{"10", "3"}, {"9", "0"}}, [][]string{{"11.5", "4"}})) {
+		t.Fatal("straddling event reported a gap")
+	}
+	if book.Bids[10] != 3 || book.LastUpdateID != 105 {
+		t.Fatalf("straddling event was not applied: %v %d", book.Bids, book.LastUpdateID)
+	}
+	if _, found := book.Bids[9]; found {
+		t.Fatal("zero quantity did not remove the level")
+	}
+	if book.Asks[11.5] != 4 {
+		t.Fatalf("new level was not added: %v", book.Asks)
+	}
+
+	// the next event follows on from the last one
+	if !book.applyDiff(depthDiff(106, 110, nil, [][]string{{"12", "0"}})) {
+		t.Fatal("contiguous event reported a gap")
+	}
+	if _, found := book.Asks[12]; found || book.LastUpdateID != 110 {
+		t.Fatalf("contiguous event was not applied: %v %d", book.Asks, book.LastUpdateID)
+	}
+
+	// a gap leaves the book as it was for a resync
+	if book.applyDiff(depthDiff(112, 115, [][]string{{"8", "1"}}, nil)) {
+		t.Fatal("gap was not reported")
+	}
+	if _, found := book.Bids[8]; found || book.LastUpdateID != 110 {
+		t.Fatalf("event after a gap was applied: %v %d", book.Bids, book.LastUpdateID)
+	}
+}
+
+func TestBinanceDepthSnapshotLimit(t *testing.T) {
+	for _, test := range []struct{ depth, limit, weight int }{
+		{20, 100, 5}, {100, 100, 5}, {101, 500, 25}, {1000, 1000, 50}, {5000, 5000, 250},
+	} {
+		if limit, weight := binanceDepthSnapshotLimit(test.depth); limit != test.limit || weight != test.weight {
+			t.Errorf("depth %d: got limit %d weight %d, want %d %d", test.depth, limit, weight, test.limit, test.weight)
+		}
+	}
+}
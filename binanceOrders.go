@@ -127,7 +127,12 @@ func binanceOrderQuery(pair string, orderid uint64) {
 	binanceCheckError(respBytes)
 }
 
-func binanceOrderCreate(pair, side, price, quantity string, stoploss, takeprofit float64, autorepeat int, reforderid uint64) {
+// binanceOrderCreate places a limit order and returns the id Binance gave it, 0 when it was not placed.
+func binanceOrderCreate(pair, side, price, quantity string, stoploss, takeprofit float64, autorepeat int, reforderid, opportunityid uint64) (orderID uint64) {
+	if isReplayMode() {
+		log.Printf("Replay: skipped %s %s %s at %s \n", side, quantity, pair, price)
+		return
+	}
 
 	orderParams := fmt.Sprintf(binanceOrderCreateParams, pair, side, price, quantity)
 	respBytes := binanceRestAPI("POST", binanceRestURL+"/order?", orderParams)
@@ -138,10 +143,15 @@ func binanceOrderCreate(pair, side, price, quantity string, stoploss, takeprofit
 	binanceOrder := binanceOrderType{}
 	json.Unmarshal(respBytes, &binanceOrder)
 
-	if binanceOrder.OrderID == 0 {
+	if orderID = binanceOrder.OrderID; orderID == 0 {
 		return
 	}
 
+	// the opportunity is taken as soon as the order exists, however late its executionReport arrives
+	if opportunityid > 0 {
+		markOpportunityTaken(opportunityid, orderID)
+	}
+
 	time.Sleep(time.Millisecond * 375)
 	newOrder := getOrder(binanceOrder.OrderID, "binance")
 	if newOrder.OrderID == 0 {
@@ -164,6 +174,7 @@ func binanceOrderCreate(pair, side, price, quantity string, stoploss, takeprofit
 	}
 
 	newOrder.RefOrderID = reforderid
+	newOrder.OpportunityID = opportunityid
 	updateOrderAndSave(newOrder, true)
 
 	if reforderid > 0 {
@@ -171,6 +182,7 @@ func binanceOrderCreate(pair, side, price, quantity string, stoploss, takeprofit
 		prvOrder.RefOrderID = binanceOrder.OrderID
 		updateOrderAndSave(prvOrder, true)
 	}
+	return
 }
 
 func binanceOrderCancel(pair string, orderid uint64) {
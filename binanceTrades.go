@@ -2,7 +2,6 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"strconv"
 	"strings"
@@ -81,42 +80,27 @@ func binanceTradeStream() {
 			continue
 		}
 
-		market := getMarket(marketPair, "binance")
-		if market.Pair == "" {
-			continue
-		}
-
+		// aggregate each print into its second, leaving market prices to the kline stream
 		trade := trades{}
-		trade.Pair = market.Pair
-		trade.Exchange = market.Exchange
-		trade.BaseAsset = market.BaseAsset
-		trade.QuoteAsset = market.QuoteAsset
+		trade.Pair = marketPair
+		trade.Exchange = "binance"
+		trade.IsBuyerMaker = wsResp.Data.IsBuyerMaker
 
+		// a buyer maker means the taker sold
 		if wsResp.Data.IsBuyerMaker {
-			trade.Side = "Buy"
-		} else {
 			trade.Side = "Sell"
+		} else {
+			trade.Side = "Buy"
 		}
 		trade.TradeID = wsResp.Data.TradeID
 		trade.Price, _ = strconv.ParseFloat(wsResp.Data.Price, 64)
 		trade.Quantity, _ = strconv.ParseFloat(wsResp.Data.Quantity, 64)
-		trade.TradeTime = fmt.Sprintf("%s", time.Unix(int64(wsResp.Data.TradeTime)/1000, 0))
-		updateTrade(trade)
-
-		// 	select {
-		// 	case wsBroadcastTrade <- trade:
-		// 	default:
-		// 	}
-		// }
+		tradeTime := time.UnixMilli(int64(wsResp.Data.TradeTime))
+		trade.TradeTime = tradeTime.Format(time.DateTime)
 
-		market.LastPrice = market.Price
-		market.Price = trade.Price
-		updateMarket(market)
-
-		// select {
-		// case chanStoplossTakeProfit <- getOrderbook(market.Pair, "binance"):
-		// default:
-		// }
+		aggregateTrade(trade.Pair, trade.Exchange, trade.Price, trade.Quantity, trade.IsBuyerMaker, tradeTime)
+		updateTrade(trade)
+		recordEvent(recordType{Kind: RecordTrade, Trade: &trade})
 	}
 	//loop through and read all messages received
 }
@@ -138,6 +138,7 @@ func crex24CheckError(respBytes []byte) {
 	if crex24Error.ErrorDescription != "" {
 		wsBroadcastNotification <- notifications{
 			Type: "info", Title: "*Crex24 Exchange*", Message: crex24Error.ErrorDescription,
+			Severity: "error", Source: "crex24",
 		}
 	}
 }
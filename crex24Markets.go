@@ -144,6 +144,8 @@ func crex24Market24hrTicker() {
 				market.HighPrice = marketPair.High
 				market.PriceChange = (marketPair.PercentChange / 100) * marketPair.Last
 				market.PriceChangePercent = marketPair.PercentChange
+				market.Volume24h = marketPair.BaseVolume
+				market.VolumeQuote24h = marketPair.QuoteVolume
 
 				if market.Status != "enabled" {
 					market.Price = marketPair.Last
@@ -196,6 +198,8 @@ func crex24Market24hrTickerStream() {
 		market.LastPrice, _ = strconv.ParseFloat(lastprice, 64)
 		market.PriceChangePercent, _ = strconv.ParseFloat(change, 64)
 		market.PriceChange = (market.PriceChangePercent / 100) * market.LastPrice
+		market.Volume24h, _ = strconv.ParseFloat(basevol, 64)
+		market.VolumeQuote24h, _ = strconv.ParseFloat(quotevol, 64)
 		updateMarket(market)
 
 		// if market.Status != "enabled" {
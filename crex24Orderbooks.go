@@ -339,6 +339,11 @@ func crex24OrderBookStream() {
 		default:
 		}
 
+		select {
+		case chanOrderbookAnalytics <- orderbook:
+		default:
+		}
+
 	}
 	logIfErr := func(err error) {
 		if err != nil {
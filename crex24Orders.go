@@ -93,7 +93,12 @@ func crex24OrderQuery(orderid uint64) {
 
 }
 
-func crex24OrderCreate(pair, side string, price, quantity, stoploss, takeprofit float64, autorepeat int, reforderid uint64) {
+// crex24OrderCreate places a limit order and returns the id Crex24 gave it, 0 when it was not placed.
+func crex24OrderCreate(pair, side string, price, quantity, stoploss, takeprofit float64, autorepeat int, reforderid, opportunityid uint64) (orderID uint64) {
+	if isReplayMode() {
+		log.Printf("Replay: skipped %s %v %s at %v \n", side, quantity, pair, price)
+		return
+	}
 
 	queryParams := fmt.Sprintf(crex24OrderCreateParams, pair, side, price, quantity)
 	respBytes := crex24RestAPI("POST", "/v2/trading/placeOrder", []byte(queryParams))
@@ -103,6 +108,7 @@ func crex24OrderCreate(pair, side string, price, quantity, stoploss, takeprofit
 
 	crex24Order := crex24OrderType{}
 	json.Unmarshal(respBytes, &crex24Order)
+	orderID = crex24Order.ID
 
 	//--> New Order being created -
 	if crex24Order.ID > 0 {
@@ -112,6 +118,7 @@ func crex24OrderCreate(pair, side string, price, quantity, stoploss, takeprofit
 		order.Takeprofit = takeprofit
 		order.AutoRepeat = autorepeat
 		order.RefOrderID = uint64(reforderid)
+		order.OpportunityID = opportunityid
 
 		order.Side = crex24Order.Side
 		order.OrderID = crex24Order.ID
@@ -148,10 +155,16 @@ func crex24OrderCreate(pair, side string, price, quantity, stoploss, takeprofit
 	}
 
 	newOrder.RefOrderID = uint64(reforderid)
+	newOrder.OpportunityID = opportunityid
 
 	updateOrderAndSave(prvOrder, true)
 
 	updateOrderAndSave(newOrder, true)
+
+	if opportunityid > 0 && crex24Order.ID > 0 {
+		markOpportunityTaken(opportunityid, crex24Order.ID)
+	}
+	return
 }
 
 func crex24OrderCancel(orderid uint64) {
@@ -38,6 +38,7 @@ var (
 type wsResponseType struct {
 	Action string
 	Result interface{}
+	Error  string `json:",omitempty"`
 }
 
 func main() {
@@ -45,6 +46,8 @@ func main() {
 	// OrderBook = orderbook.NewOrderBook()
 	utils.RotateLogs("")
 	utils.Init(nil)
+	autoTradeEnabled = utils.Config.AutoTrade.Enabled
+	startNotifiers()
 
 	// crex24Keys()
 	binanceKeys()
@@ -52,12 +55,29 @@ func main() {
 	LoadAssetsFromDB()
 	LoadOrdersFromDB()
 	LoadMarketsFromDB()
+	LoadAlertsFromDB()
 
 	muxRouter := mux.NewRouter()
 	muxRouter.HandleFunc("/api/v1/kline", restHandlerKline).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/kline/volumeprofile", restHandlerVolumeProfile).Methods("GET")
 	muxRouter.HandleFunc("/api/v1/analysis", restHandlerAnalysis).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/orderbook", restHandlerOrderbook).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/orderbook/slippage", restHandlerSlippage).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/orderbook/analytics", restHandlerOrderbookAnalytics).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/trades", restHandlerTrades).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/recorder", restHandlerRecorder).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/recorder/replay", restHandlerRecorderReplay).Methods("POST", "DELETE")
 	muxRouter.HandleFunc("/api/v1/opportunity", restHandlerOpportunity).Methods("GET")
 	muxRouter.HandleFunc("/api/v1/opportunity/search", restHandlerSearchOpportunity).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/opportunity/explain", restHandlerExplainOpportunity).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/opportunity/stats", restHandlerOpportunityStats).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/opportunity/execute", restHandlerExecuteOpportunity).Methods("POST")
+	muxRouter.HandleFunc("/api/v1/autotrade", restHandlerAutoTrade).Methods("GET", "POST")
+	muxRouter.HandleFunc("/api/v1/signals/webhook", restHandlerSignalWebhook).Methods("POST")
+	muxRouter.HandleFunc("/api/v1/screener", restHandlerScreener).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/alerts", restHandlerAlerts).Methods("GET", "POST", "PUT", "DELETE")
+	muxRouter.HandleFunc("/api/v1/notifications", restHandlerNotifications).Methods("GET")
+	muxRouter.HandleFunc("/api/v1/notifications/ack", restHandlerAcknowledgeNotifications).Methods("POST")
 
 	wsHandlerAssetBroadcast()
 	muxRouter.HandleFunc("/websocket/assets", wsHandlerAssets)
@@ -78,11 +98,15 @@ func main() {
 	wsHandlerOrderbookBroadcast()
 	muxRouter.HandleFunc("/websocket/orderbooks", wsHandlerOrderbooks)
 
+	wsHandlerOrderbookAnalyticsBroadcast()
+	muxRouter.HandleFunc("/websocket/orderbookanalytics", wsHandlerOrderbookAnalytics)
+
 	wsHandlerAnalysisBroadcast()
 	muxRouter.HandleFunc("/websocket/analysis", wsHandlerAnalysis)
 
 	// run our strategy process
 	go apiStrategyStopLossTakeProfit()
+	go apiOrderbookAnalytics()
 
 	go binanceAssetGet()
 	wg := sync.WaitGroup{}
@@ -90,13 +114,30 @@ func main() {
 	wg.Add(1)
 	go binanceGetExistingMarkets(&wg)
 	wg.Wait()
-	go binanceAssetStream()
-	go GoFetchEnabledMarketsAnalysis()
-
-	// go binanceTradeStream() //disabled due to not being needed and data overflooding and high cpu usage
-	go binanceOrderBookStream()
-	go binanceMarket24hrTicker()
-	go binanceMarketOHLCVStream()
+	go apiAlertsEvaluate()
+
+	go GoRecorder()
+
+	// replaying recordings stands in for the live market streams and analysis, so a session can be
+	// debugged offline without touching the account, the database or the notifiers
+	if isReplayMode() {
+		if len(utils.Config.Recorder.Replay) > 0 {
+			go func() {
+				if err := replayRecordings(utils.Config.Recorder.Replay, utils.Config.Recorder.ReplaySpeed, time.Time{}, time.Time{}); err != nil {
+					log.Printf("Replay: %v \n", err)
+				}
+			}()
+		}
+	} else {
+		go binanceAssetStream()
+		go GoFetchEnabledMarketsAnalysis()
+		go GoEvaluateOpportunityOutcomes()
+		go GoSelectMarkets()
+		go binanceTradeStream()
+		go binanceOrderBookStream()
+		go binanceMarket24hrTicker()
+		go binanceMarketOHLCVStream()
+	}
 
 	//
 
@@ -136,10 +177,12 @@ func main() {
 	go log.Println(http.ListenAndServe(utils.Config.Address, nil))
 	println("listening")
 
-	sigCh := make(chan os.Signal)
+	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	select {
 	case <-sigCh:
+		closeRecording()
+
 		stackBuffer := make([]byte, 1<<16)
 		runtime.Stack(stackBuffer, true)
 
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	AlertPrice     = "price"
+	AlertChange    = "change"
+	AlertRSI       = "rsi"
+	AlertBollinger = "bollinger"
+	AlertVolume    = "volume"
+
+	AlertActive    = "active"
+	AlertTriggered = "triggered"
+	AlertExpired   = "expired"
+)
+
+type Alert struct {
+	Base
+
+	Pair     string `json:"Pair" gorm:"index;not null"`
+	Exchange string `json:"Exchange" gorm:"index;not null"`
+
+	Kind      string  `json:"Kind" gorm:"index;not null"`
+	Direction string  `json:"Direction" gorm:"index;"`
+	Level     float64 `json:"Level"`
+	Interval  string  `json:"Interval"`
+	Window    int64   `json:"Window"`
+	Message   string  `json:"Message"`
+
+	Recurring     int       `json:"Recurring" gorm:"index;"`
+	Expiry        time.Time `json:"Expiry" gorm:"index;"`
+	TriggerCount  int       `json:"TriggerCount" gorm:"column:triggercount"`
+	LastTriggered time.Time `json:"LastTriggered" gorm:"column:lasttriggered"`
+}
+
+func (model *Alert) BeforeCreate(tx *gorm.DB) error {
+	if err := model.Base.BeforeCreate(tx); err != nil {
+		return err
+	}
+
+	if model.Pair == "" {
+		return errors.New("Pair is required")
+	}
+
+	if model.Exchange == "" {
+		return errors.New("Exchange is required")
+	}
+
+	if model.Kind == "" {
+		return errors.New("Kind is required")
+	}
+
+	return nil
+}
+
+func (model *Alert) BeforeUpdate(tx *gorm.DB) error {
+	if err := model.Base.BeforeUpdate(tx); err != nil {
+		return err
+	}
+
+	return nil
+}
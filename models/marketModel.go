@@ -12,8 +12,9 @@ type Market struct {
 	Pair     string `json:"Pair" gorm:"uniqueIndex:idx_market_exchange_pair;not null"`
 	Exchange string `json:"Exchange" gorm:"uniqueIndex:idx_market_exchange_pair;not null"`
 
-	NumOfTrades int `json:"NumOfTrades" gorm:"index; not null;column:numoftrades"`
-	Closed      int `json:"Closed" gorm:"->;-:migration"` // Read-only, disable migration
+	NumOfTrades   int    `json:"NumOfTrades" gorm:"index; not null;column:numoftrades"`
+	TradingStatus string `json:"TradingStatus" gorm:"index;column:tradingstatus"`
+	Closed        int    `json:"Closed" gorm:"->;-:migration"` // Read-only, disable migration
 
 	BaseAsset  string `json:"BaseAsset" gorm:"index; not null;column:baseasset"`
 	QuoteAsset string `json:"QuoteAsset" gorm:"index; not null;column:quoteasset"`
@@ -36,6 +37,11 @@ type Market struct {
 	LastPrice   float64 `json:"LastPrice" gorm:"index;column:lastprice"`
 	Price       float64 `json:"Price" gorm:"index;"`
 
+	// Volume24h and VolumeQuote24h are the rolling 24h volumes of the ticker, Volume and VolumeQuote
+	// holding the current candle's of an enabled market
+	Volume24h      float64 `json:"Volume24h" gorm:"column:volume24h;default:0"`
+	VolumeQuote24h float64 `json:"VolumeQuote24h" gorm:"index;column:volumequote24h;default:0"`
+
 	UpperBand  float64 `json:"UpperBand" gorm:"index;column:upperband"`
 	MiddleBand float64 `json:"MiddleBand" gorm:"index;column:middleband"`
 	LowerBand  float64 `json:"LowerBand" gorm:"index;column:lowerband"`
@@ -44,7 +50,11 @@ type Market struct {
 	PriceChangePercent float64 `json:"PriceChangePercent" gorm:"index;column:pricechangepercent"`
 	HighPrice          float64 `json:"HighPrice" gorm:"index;column:highprice"`
 	LowPrice           float64 `json:"LowPrice" gorm:"index;column:lowprice"`
+	Spread             float64 `json:"Spread" gorm:"column:spread;default:0"`
 	RSI                float64 `json:"RSI" gorm:"default:0"`
+
+	AutoTrade      int     `json:"AutoTrade" gorm:"index;column:autotrade;default:0"`
+	AutoTradeQuote float64 `json:"AutoTradeQuote" gorm:"column:autotradequote;default:0"`
 }
 
 func (model *Market) BeforeCreate(tx *gorm.DB) error {
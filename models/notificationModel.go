@@ -0,0 +1,51 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	NotificationUnread       = "unread"
+	NotificationRead         = "read"
+	NotificationAcknowledged = "acknowledged"
+)
+
+type Notification struct {
+	Base
+
+	Type     string `json:"Type" gorm:"index;"`
+	Severity string `json:"Severity" gorm:"index;"`
+	Source   string `json:"Source" gorm:"index;"`
+
+	Title   string `json:"Title"`
+	Message string `json:"Message"`
+
+	Pair          string `json:"Pair" gorm:"index;"`
+	OrderID       uint64 `json:"OrderID" gorm:"index;column:orderid"`
+	OpportunityID uint64 `json:"OpportunityID" gorm:"index;column:opportunityid"`
+
+	Acknowledgedate time.Time `json:"Acknowledgedate" gorm:"column:acknowledgedate"`
+}
+
+func (model *Notification) BeforeCreate(tx *gorm.DB) error {
+	if err := model.Base.BeforeCreate(tx); err != nil {
+		return err
+	}
+
+	if model.Title == "" && model.Message == "" {
+		return errors.New("Title or Message is required")
+	}
+
+	return nil
+}
+
+func (model *Notification) BeforeUpdate(tx *gorm.DB) error {
+	if err := model.Base.BeforeUpdate(tx); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -4,12 +4,26 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	OpportunityTPHit   = "tp-hit"
+	OpportunitySLHit   = "sl-hit"
+	OpportunityExpired = "expired"
+
+	OpportunityPending = "pending"
+	OpportunityTaken   = "taken"
+
+	OpportunitySourceAnalysis = "analysis"
+	OpportunitySourceWebhook  = "webhook"
+)
+
 type Opportunity struct {
 	Base
+	Source    string `json:"Source" gorm:"index;"`
 	Trend     string `json:"Trend" gorm:"index;"`
 	Pair      string `json:"Pair" gorm:"index;not null"`
 	Action    string `json:"Action" gorm:"index;"`
@@ -19,6 +33,15 @@ type Opportunity struct {
 	Price      float64 `json:"Price" gorm:"index;"`
 	Stoploss   float64 `json:"Stoploss" gorm:"index;"`
 	Takeprofit float64 `json:"Takeprofit" gorm:"index;"`
+	Score      float64 `json:"Score" gorm:"index;"`
+	OrderID    uint64  `json:"OrderID" gorm:"index;column:orderid"`
+
+	Outcome       string    `json:"Outcome" gorm:"index;"`
+	OutcomePrice  float64   `json:"OutcomePrice"`
+	OutcomeDate   time.Time `json:"OutcomeDate" gorm:"column:outcomedate;"`
+	TimeToOutcome int64     `json:"TimeToOutcome" gorm:"column:timetooutcome;"`
+	MaxFavourable float64   `json:"MaxFavourable" gorm:"column:maxfavourable;"`
+	MaxAdverse    float64   `json:"MaxAdverse" gorm:"column:maxadverse;"`
 
 	Analysis JSONB `json:"Analysis" gorm:"type:jsonb;"`
 }
@@ -24,6 +24,8 @@ type Order struct {
 	RefOrderID uint64 `json:"RefOrderID" gorm:"index;column:reforderid"`
 	RefEnabled int    `json:"RefEnabled" gorm:"index;column:refenabled"`
 
+	OpportunityID uint64 `json:"OpportunityID" gorm:"index;column:opportunityid"`
+
 	Price      float64 `json:"Price" gorm:"index;not null"`
 	Quantity   float64 `json:"Quantity" gorm:"index;not null"`
 	Total      float64 `json:"Total" gorm:"index;not null"`
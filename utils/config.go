@@ -8,6 +8,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -30,6 +32,23 @@ const (
 	nonceSize = 24
 )
 
+// NotifierConfig is one outbound notification channel: telegram, slack, smtp or webhook.
+// Types routes notification Types to the channel, every Type when empty.
+type NotifierConfig struct {
+	Name, Kind string
+	Types      []string
+	RateLimit  int
+	Retries    int
+
+	URL, Token, ChatID string
+
+	Host               string
+	Port               int
+	Username, Password string
+	From               string
+	To                 []string
+}
+
 // Config structure
 type configType struct {
 	Timezone, Cookie, Path,
@@ -44,11 +63,113 @@ type configType struct {
 	Crex24  struct{ Key, Secret string }
 	Binance struct{ Key, Secret string }
 
+	ChartPatterns ChartPatternConfig
+
+	Opportunity struct {
+		Threshold     float64
+		Weights       map[string]float64
+		ExpiryCandles int
+	}
+
+	MarketSelection struct {
+		Enabled                      bool
+		Interval                     time.Duration
+		TopN                         int
+		QuoteAssets                  []string
+		MinVolumeQuote, MaxSpread    float64
+		MinVolatility, MaxVolatility float64
+	}
+
+	Listings struct {
+		Interval          time.Duration
+		WatchedBaseAssets []string
+	}
+
+	Notifiers []NotifierConfig
+
+	// Screener.Weight is the klines request weight a minute the screener may spend, shared with the
+	// trading streams, and MaxFetch the markets one request may fetch klines for
+	Screener struct {
+		Workers, Weight, MaxFetch int
+	}
+
+	// Orderbook.Depth is the levels a side kept of the local books, ClientDepth what a client gets
+	// unless it asks for more, and SnapshotWeight the request weight a minute resyncs may spend
+	Orderbook struct {
+		Depth, ClientDepth int
+		Interval           time.Duration
+		SnapshotWeight     int
+	}
+
+	Slippage struct {
+		MaxBps float64
+	}
+
+	VolumeProfile struct {
+		Bins      int
+		ValueArea float64
+	}
+
+	Trades struct {
+		Window        time.Duration
+		LargeMultiple float64
+		LargeQuote    float64
+	}
+
+	Websocket struct {
+		QueueSize    int
+		WriteTimeout time.Duration
+		SlowConsumer time.Duration
+		History      int
+	}
+
+	Recorder struct {
+		Enabled     bool
+		Path        string
+		Depth       int
+		Rotate      time.Duration
+		Replay      []string
+		ReplaySpeed float64
+		// ReplayMode starts the process without the live market streams, orders, database writes
+		// or notifiers, set by replaymode or by naming recordings to replay on start
+		ReplayMode bool
+	}
+
+	OrderbookAnalytics struct {
+		Bands                             []float64
+		WallMultiple                      float64
+		SpoofWindow, SpreadWindow         time.Duration
+		ImbalanceBand, ImbalanceThreshold float64
+	}
+
+	Webhook struct {
+		Secret    string
+		Execute   bool
+		Tolerance time.Duration
+		Dedupe    time.Duration
+	}
+
+	AutoTrade struct {
+		Enabled     bool
+		Cooldown    time.Duration
+		QuoteAmount float64
+		AutoRepeat  int
+	}
+
 	dbConfig map[string]string
 
 	CGate, CSplash map[string]string
 }
 
+// opportunityConditions are the rules of the long and short checks that can be weighted in config.yaml
+var opportunityConditions = []string{"rsi", "fib", "trend", "bollinger", "support", "resistance"}
+
+// opportunityOrderbookConditions are the orderbook analytics rules, left out of the score until weighted
+var opportunityOrderbookConditions = []string{"imbalance", "wall"}
+
+// opportunityVolumeConditions are the volume profile rules, left out of the score until weighted
+var opportunityVolumeConditions = []string{"volumeprofile"}
+
 // Config to be exported globally
 var (
 	Config configType
@@ -60,6 +181,60 @@ func Init(yamlConfig []byte) {
 
 	viper.SetConfigType("yaml")
 	viper.SetDefault("address", "127.0.0.1:8080")
+	viper.SetDefault("chartpatterns.swinglookback", DefaultChartPatternConfig.SwingLookback)
+	viper.SetDefault("chartpatterns.window", DefaultChartPatternConfig.Window)
+	viper.SetDefault("chartpatterns.tolerance", DefaultChartPatternConfig.Tolerance)
+	viper.SetDefault("chartpatterns.zonetolerance", DefaultChartPatternConfig.ZoneTolerance)
+	viper.SetDefault("chartpatterns.swingatrmultiplier", DefaultChartPatternConfig.SwingATRMultiplier)
+	viper.SetDefault("opportunity.threshold", 1.0)
+	viper.SetDefault("opportunity.expirycandles", 96)
+	viper.SetDefault("screener.workers", 4)
+	viper.SetDefault("screener.weight", 600)
+	viper.SetDefault("screener.maxfetch", 100)
+	viper.SetDefault("orderbook.depth", 20)
+	viper.SetDefault("orderbook.clientdepth", 20)
+	viper.SetDefault("orderbook.snapshotweight", 600)
+	viper.SetDefault("orderbook.interval", "1s")
+	viper.SetDefault("listings.interval", "15m")
+	viper.SetDefault("marketselection.enabled", false)
+	viper.SetDefault("marketselection.interval", "30m")
+	viper.SetDefault("marketselection.topn", 5)
+	viper.SetDefault("marketselection.quoteassets", []string{"USDT"})
+	viper.SetDefault("marketselection.minvolumequote", 1000000)
+	viper.SetDefault("marketselection.maxspread", 0.2)
+	viper.SetDefault("marketselection.minvolatility", 1)
+	viper.SetDefault("marketselection.maxvolatility", 0)
+	viper.SetDefault("webhook.tolerance", "5m")
+	viper.SetDefault("webhook.dedupe", "24h")
+	viper.SetDefault("autotrade.enabled", false)
+	viper.SetDefault("autotrade.cooldown", "1h")
+	viper.SetDefault("autotrade.autorepeat", 0)
+	for _, condition := range opportunityConditions {
+		viper.SetDefault("opportunity.weights."+condition, 1)
+	}
+	for _, condition := range append(opportunityOrderbookConditions, opportunityVolumeConditions...) {
+		viper.SetDefault("opportunity.weights."+condition, 0)
+	}
+	viper.SetDefault("volumeprofile.bins", 24)
+	viper.SetDefault("volumeprofile.valuearea", 0.7)
+	viper.SetDefault("trades.window", "15m")
+	viper.SetDefault("trades.largemultiple", 10)
+	viper.SetDefault("websocket.queuesize", 256)
+	viper.SetDefault("websocket.writetimeout", "10s")
+	viper.SetDefault("websocket.slowconsumer", "30s")
+	viper.SetDefault("websocket.history", 1024)
+	viper.SetDefault("recorder.enabled", false)
+	viper.SetDefault("recorder.path", "recordings")
+	viper.SetDefault("recorder.depth", 100)
+	viper.SetDefault("recorder.rotate", "1h")
+	viper.SetDefault("recorder.replayspeed", 1)
+	viper.SetDefault("recorder.replaymode", false)
+	viper.SetDefault("orderbookanalytics.bands", []interface{}{0.1, 0.5, 1, 2})
+	viper.SetDefault("orderbookanalytics.wallmultiple", 5)
+	viper.SetDefault("orderbookanalytics.spoofwindow", "30s")
+	viper.SetDefault("orderbookanalytics.spreadwindow", "15m")
+	viper.SetDefault("orderbookanalytics.imbalanceband", 0.5)
+	viper.SetDefault("orderbookanalytics.imbalancethreshold", 0.2)
 
 	var err error
 	if yamlConfig == nil {
@@ -91,6 +266,112 @@ func Init(yamlConfig []byte) {
 		Config.Binance.Secret = binanceMap["secret"]
 	}
 
+	Config.ChartPatterns.SwingLookback = viper.GetInt("chartpatterns.swinglookback")
+	Config.ChartPatterns.Window = viper.GetInt("chartpatterns.window")
+	Config.ChartPatterns.Tolerance = viper.GetFloat64("chartpatterns.tolerance")
+	Config.ChartPatterns.ZoneTolerance = viper.GetFloat64("chartpatterns.zonetolerance")
+	Config.ChartPatterns.SwingATRMultiplier = viper.GetFloat64("chartpatterns.swingatrmultiplier")
+
+	Config.Opportunity.Threshold = viper.GetFloat64("opportunity.threshold")
+	if Config.Opportunity.Threshold <= 0 {
+		log.Fatalf("opportunity.threshold must be above 0, got %v", Config.Opportunity.Threshold)
+	}
+	Config.Opportunity.ExpiryCandles = viper.GetInt("opportunity.expirycandles")
+
+	Config.Screener.Workers = viper.GetInt("screener.workers")
+	Config.Screener.Weight = viper.GetInt("screener.weight")
+	Config.Screener.MaxFetch = viper.GetInt("screener.maxfetch")
+
+	Config.Orderbook.Depth = viper.GetInt("orderbook.depth")
+	if Config.Orderbook.Depth <= 0 || Config.Orderbook.Depth > 5000 {
+		Config.Orderbook.Depth = 5000
+	}
+	Config.Orderbook.ClientDepth = viper.GetInt("orderbook.clientdepth")
+	if Config.Orderbook.ClientDepth <= 0 || Config.Orderbook.ClientDepth > Config.Orderbook.Depth {
+		Config.Orderbook.ClientDepth = Config.Orderbook.Depth
+	}
+	Config.Orderbook.Interval = viper.GetDuration("orderbook.interval")
+	Config.Orderbook.SnapshotWeight = viper.GetInt("orderbook.snapshotweight")
+
+	Config.Slippage.MaxBps = viper.GetFloat64("slippage.maxbps")
+
+	Config.VolumeProfile.Bins = viper.GetInt("volumeprofile.bins")
+	Config.VolumeProfile.ValueArea = viper.GetFloat64("volumeprofile.valuearea")
+
+	Config.Trades.Window = viper.GetDuration("trades.window")
+	if Config.Trades.Window <= 0 {
+		Config.Trades.Window = time.Minute * 15
+	}
+	Config.Trades.LargeMultiple = viper.GetFloat64("trades.largemultiple")
+	Config.Trades.LargeQuote = viper.GetFloat64("trades.largequote")
+
+	Config.Websocket.QueueSize = viper.GetInt("websocket.queuesize")
+	if Config.Websocket.QueueSize <= 0 {
+		Config.Websocket.QueueSize = 256
+	}
+	Config.Websocket.WriteTimeout = viper.GetDuration("websocket.writetimeout")
+	if Config.Websocket.WriteTimeout <= 0 {
+		Config.Websocket.WriteTimeout = time.Second * 10
+	}
+	Config.Websocket.SlowConsumer = viper.GetDuration("websocket.slowconsumer")
+	Config.Websocket.History = viper.GetInt("websocket.history")
+
+	Config.Recorder.Enabled = viper.GetBool("recorder.enabled")
+	Config.Recorder.Path = viper.GetString("recorder.path")
+	Config.Recorder.Depth = viper.GetInt("recorder.depth")
+	Config.Recorder.Rotate = viper.GetDuration("recorder.rotate")
+	if Config.Recorder.Rotate <= 0 {
+		Config.Recorder.Rotate = time.Hour
+	}
+	Config.Recorder.Replay = viper.GetStringSlice("recorder.replay")
+	Config.Recorder.ReplaySpeed = viper.GetFloat64("recorder.replayspeed")
+	Config.Recorder.ReplayMode = viper.GetBool("recorder.replaymode") || len(Config.Recorder.Replay) > 0
+
+	for _, band := range viper.GetStringSlice("orderbookanalytics.bands") {
+		if value, err := strconv.ParseFloat(band, 64); err == nil && value > 0 {
+			Config.OrderbookAnalytics.Bands = append(Config.OrderbookAnalytics.Bands, value)
+		}
+	}
+	sort.Float64s(Config.OrderbookAnalytics.Bands)
+	Config.OrderbookAnalytics.WallMultiple = viper.GetFloat64("orderbookanalytics.wallmultiple")
+	Config.OrderbookAnalytics.SpoofWindow = viper.GetDuration("orderbookanalytics.spoofwindow")
+	Config.OrderbookAnalytics.SpreadWindow = viper.GetDuration("orderbookanalytics.spreadwindow")
+	Config.OrderbookAnalytics.ImbalanceBand = viper.GetFloat64("orderbookanalytics.imbalanceband")
+	Config.OrderbookAnalytics.ImbalanceThreshold = viper.GetFloat64("orderbookanalytics.imbalancethreshold")
+
+	if err := viper.UnmarshalKey("notifiers", &Config.Notifiers); err != nil {
+		log.Printf("Error reading notifiers: %v \n", err)
+	}
+
+	Config.Listings.Interval = viper.GetDuration("listings.interval")
+	if Config.Listings.Interval <= 0 {
+		Config.Listings.Interval = time.Hour * 6
+	}
+	Config.Listings.WatchedBaseAssets = viper.GetStringSlice("listings.watchedbaseassets")
+
+	Config.MarketSelection.Enabled = viper.GetBool("marketselection.enabled")
+	Config.MarketSelection.Interval = viper.GetDuration("marketselection.interval")
+	Config.MarketSelection.TopN = viper.GetInt("marketselection.topn")
+	Config.MarketSelection.QuoteAssets = viper.GetStringSlice("marketselection.quoteassets")
+	Config.MarketSelection.MinVolumeQuote = viper.GetFloat64("marketselection.minvolumequote")
+	Config.MarketSelection.MaxSpread = viper.GetFloat64("marketselection.maxspread")
+	Config.MarketSelection.MinVolatility = viper.GetFloat64("marketselection.minvolatility")
+	Config.MarketSelection.MaxVolatility = viper.GetFloat64("marketselection.maxvolatility")
+
+	Config.Webhook.Secret = viper.GetString("webhook.secret")
+	Config.Webhook.Execute = viper.GetBool("webhook.execute")
+	Config.Webhook.Tolerance = viper.GetDuration("webhook.tolerance")
+	Config.Webhook.Dedupe = viper.GetDuration("webhook.dedupe")
+
+	Config.AutoTrade.Enabled = viper.GetBool("autotrade.enabled")
+	Config.AutoTrade.Cooldown = viper.GetDuration("autotrade.cooldown")
+	Config.AutoTrade.QuoteAmount = viper.GetFloat64("autotrade.quoteamount")
+	Config.AutoTrade.AutoRepeat = viper.GetInt("autotrade.autorepeat")
+	Config.Opportunity.Weights = make(map[string]float64)
+	for _, condition := range append(append(opportunityConditions, opportunityOrderbookConditions...), opportunityVolumeConditions...) {
+		Config.Opportunity.Weights[condition] = viper.GetFloat64("opportunity.weights." + condition)
+	}
+
 	encrptionKeysMap := viper.GetStringMapString("encryption_keys")
 	if encrptionKeysMap != nil {
 		Config.Encryption.Public, err = Asset(encrptionKeysMap["public"])
@@ -138,12 +419,48 @@ func ConnectDB() {
 	// modelsList = append(modelsList, &models.Order{})
 	// modelsList = append(modelsList, &models.Market{})
 	modelsList = append(modelsList, &models.Opportunity{})
+	modelsList = append(modelsList, &models.Alert{})
+	modelsList = append(modelsList, &models.Notification{})
 	if err := SqlDB.AutoMigrate(modelsList...); err != nil {
 		log.Panicf("Error migrating database: %v", err)
 	}
+	migrateColumns()
 
 }
 
+// migrateColumns adds the columns and indexes later given to orders and markets, whose tables are
+// left out of AutoMigrate, as listed in dbstructure.txt.
+func migrateColumns() {
+	columns := []struct {
+		model interface{}
+		field string
+		index bool
+	}{
+		{&models.Order{}, "OpportunityID", true},
+		{&models.Market{}, "TradingStatus", true},
+		{&models.Market{}, "Spread", false},
+		{&models.Market{}, "AutoTrade", true},
+		{&models.Market{}, "AutoTradeQuote", false},
+		{&models.Market{}, "Volume24h", false},
+		{&models.Market{}, "VolumeQuote24h", true},
+	}
+
+	migrator := SqlDB.Migrator()
+	for _, column := range columns {
+		if !migrator.HasColumn(column.model, column.field) {
+			if err := migrator.AddColumn(column.model, column.field); err != nil {
+				log.Panicf("Error adding column %s: %v", column.field, err)
+			}
+		}
+
+		if column.index && !migrator.HasIndex(column.model, column.field) {
+			if err := migrator.CreateIndex(column.model, column.field); err != nil {
+				log.Panicf("Error adding index on %s: %v", column.field, err)
+			}
+		}
+	}
+}
+
 // Encrypt ...
 func Encrypt(in []byte) (out []byte) {
 	key, nonce := keyNounce()
@@ -92,40 +92,40 @@ func isLongLeggedDoji(c Candle) bool {
 	return math.Abs(c.Open-c.Close) <= precision && (c.High-c.Low) > 4*(math.Abs(c.Open-c.Close))
 }
 
-// isBullishHammer checks for a Bullish Hammer pattern (small body near high, long lower shadow).
-func isBullishHammer(c Candle) bool {
+// isBullishHammer checks for a Bullish Hammer pattern (small body near high, long lower shadow) after a downtrend.
+func isBullishHammer(c Candle, priorTrend string) bool {
 	// precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
 	body := math.Abs(c.Open - c.Close)
 	lowerShadow := math.Min(c.Open, c.Close) - c.Low
 	upperShadow := c.High - math.Max(c.Open, c.Close)
-	return lowerShadow > 2*body && upperShadow <= lowerShadow && c.Close > c.Open
+	return priorTrend == Bearish && lowerShadow > 2*body && upperShadow <= lowerShadow && c.Close > c.Open
 }
 
-// isBullishInvertedHammer checks for a Bullish Inverted Hammer pattern (small body near low, long upper shadow).
-func isBullishInvertedHammer(c Candle) bool {
+// isBullishInvertedHammer checks for a Bullish Inverted Hammer pattern (small body near low, long upper shadow) after a downtrend.
+func isBullishInvertedHammer(c Candle, priorTrend string) bool {
 	// precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
 	body := math.Abs(c.Open - c.Close)
 	upperShadow := c.High - math.Max(c.Open, c.Close)
 	lowerShadow := math.Min(c.Open, c.Close) - c.Low
-	return upperShadow > 2*body && lowerShadow <= upperShadow && c.Close > c.Open
+	return priorTrend == Bearish && upperShadow > 2*body && lowerShadow <= upperShadow && c.Close > c.Open
 }
 
-// isBearishHangingMan checks for a Bearish Hanging Man pattern (small body near high, long lower shadow).
-func isBearishHangingMan(c Candle) bool {
+// isBearishHangingMan checks for a Bearish Hanging Man pattern (small body near high, long lower shadow) after an uptrend.
+func isBearishHangingMan(c Candle, priorTrend string) bool {
 	// precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
 	body := math.Abs(c.Open - c.Close)
 	lowerShadow := math.Min(c.Open, c.Close) - c.Low
 	upperShadow := c.High - math.Max(c.Open, c.Close)
-	return lowerShadow > 2*body && upperShadow <= lowerShadow && c.Close < c.Open
+	return priorTrend == Bullish && lowerShadow > 2*body && upperShadow <= lowerShadow && c.Close < c.Open
 }
 
-// isBearishShootingStar checks for a Bearish Shooting Star pattern (small body near low, long upper shadow).
-func isBearishShootingStar(c Candle) bool {
+// isBearishShootingStar checks for a Bearish Shooting Star pattern (small body near low, long upper shadow) after an uptrend.
+func isBearishShootingStar(c Candle, priorTrend string) bool {
 	// precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
 	body := math.Abs(c.Open - c.Close)
 	upperShadow := c.High - math.Max(c.Open, c.Close)
 	lowerShadow := math.Min(c.Open, c.Close) - c.Low
-	return upperShadow > 2*body && lowerShadow <= upperShadow && c.Close < c.Open
+	return priorTrend == Bullish && upperShadow > 2*body && lowerShadow <= upperShadow && c.Close < c.Open
 }
 
 // - two candle stick patterns - //
@@ -211,17 +211,17 @@ func isBearishThreeBlackCrows(last3 []Candle) bool {
 		last3[2].Close < last3[1].Low
 }
 
-// Morning Star: Three candle pattern, first bearish, second small-bodied, third bullish
-func isBullishMorningStar(last3 []Candle) bool {
-	return last3[0].Close < last3[0].Open &&
+// Morning Star: Three candle pattern after a downtrend, first bearish, second small-bodied, third bullish
+func isBullishMorningStar(last3 []Candle, priorTrend string) bool {
+	return priorTrend == Bearish && last3[0].Close < last3[0].Open &&
 		math.Abs(last3[1].Open-last3[1].Close) < math.Abs(last3[0].Open-last3[0].Close) &&
 		last3[2].Close > last3[2].Open &&
 		last3[2].Close > (last3[0].Open-last3[0].Close)/2
 }
 
-// Evening Star: Three candle pattern, first bullish, second small-bodied, third bearish
-func isBearishEveningStar(last3 []Candle) bool {
-	return last3[0].Close > last3[0].Open &&
+// Evening Star: Three candle pattern after an uptrend, first bullish, second small-bodied, third bearish
+func isBearishEveningStar(last3 []Candle, priorTrend string) bool {
+	return priorTrend == Bullish && last3[0].Close > last3[0].Open &&
 		math.Abs(last3[1].Open-last3[1].Close) < math.Abs(last3[0].Close-last3[0].Open) &&
 		last3[2].Close < last3[2].Open &&
 		last3[2].Close < (last3[0].Close-last3[0].Open)/2
@@ -273,3 +273,191 @@ func isBullishThreeLineStrike(last4 []Candle) bool {
 		last4[3].Open >= last4[2].Close &&
 		last4[3].Close < last4[0].Open
 }
+
+// - trend context helpers - //
+
+// candleBody returns the absolute size of the candle body.
+func candleBody(c Candle) float64 {
+	return math.Abs(c.Close - c.Open)
+}
+
+// isLongBody checks that the body covers most of the candle range.
+func isLongBody(c Candle) bool {
+	return c.High > c.Low && candleBody(c) >= 0.6*(c.High-c.Low)
+}
+
+// isDoji checks for an open and close that are practically equal.
+func isDoji(c Candle) bool {
+	precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
+	return candleBody(c) <= precision || (c.High > c.Low && candleBody(c) <= 0.1*(c.High-c.Low))
+}
+
+// isBodyInside checks that the body of inner sits within the body of outer.
+func isBodyInside(inner, outer Candle) bool {
+	return math.Max(inner.Open, inner.Close) <= math.Max(outer.Open, outer.Close) &&
+		math.Min(inner.Open, inner.Close) >= math.Min(outer.Open, outer.Close)
+}
+
+// - additional one candle stick patterns - //
+
+// isBullishBeltHold checks for a long green candle opening at its low after a downtrend.
+func isBullishBeltHold(c Candle, priorTrend string) bool {
+	precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
+	return priorTrend == Bearish && c.Close > c.Open && isLongBody(c) &&
+		math.Abs(c.Open-c.Low) <= precision
+}
+
+// isBearishBeltHold checks for a long red candle opening at its high after an uptrend.
+func isBearishBeltHold(c Candle, priorTrend string) bool {
+	precision := calculatePrecision(c.Open, c.High, c.Low, c.Close)
+	return priorTrend == Bullish && c.Close < c.Open && isLongBody(c) &&
+		math.Abs(c.Open-c.High) <= precision
+}
+
+// - additional two candle stick patterns - //
+
+// isBullishHarami checks for a small green body inside the previous long red body after a downtrend.
+func isBullishHarami(prev, curr Candle, priorTrend string) bool {
+	return priorTrend == Bearish && prev.Close < prev.Open && isLongBody(prev) &&
+		curr.Close > curr.Open && candleBody(curr) < candleBody(prev) && isBodyInside(curr, prev)
+}
+
+// isBearishHarami checks for a small red body inside the previous long green body after an uptrend.
+func isBearishHarami(prev, curr Candle, priorTrend string) bool {
+	return priorTrend == Bullish && prev.Close > prev.Open && isLongBody(prev) &&
+		curr.Close < curr.Open && candleBody(curr) < candleBody(prev) && isBodyInside(curr, prev)
+}
+
+// isBullishHaramiCross checks for a doji inside the previous long red body after a downtrend.
+func isBullishHaramiCross(prev, curr Candle, priorTrend string) bool {
+	return priorTrend == Bearish && prev.Close < prev.Open && isLongBody(prev) &&
+		isDoji(curr) && isBodyInside(curr, prev)
+}
+
+// isBearishHaramiCross checks for a doji inside the previous long green body after an uptrend.
+func isBearishHaramiCross(prev, curr Candle, priorTrend string) bool {
+	return priorTrend == Bullish && prev.Close > prev.Open && isLongBody(prev) &&
+		isDoji(curr) && isBodyInside(curr, prev)
+}
+
+// isBullishPiercingLine checks for a green candle opening below the previous red close and closing above its midpoint.
+func isBullishPiercingLine(prev, curr Candle, priorTrend string) bool {
+	midpoint := (prev.Open + prev.Close) / 2
+	return priorTrend == Bearish && prev.Close < prev.Open && curr.Close > curr.Open &&
+		curr.Open < prev.Close && curr.Close > midpoint && curr.Close < prev.Open
+}
+
+// isBearishDarkCloudCover checks for a red candle opening above the previous green close and closing below its midpoint.
+func isBearishDarkCloudCover(prev, curr Candle, priorTrend string) bool {
+	midpoint := (prev.Open + prev.Close) / 2
+	return priorTrend == Bullish && prev.Close > prev.Open && curr.Close < curr.Open &&
+		curr.Open > prev.Close && curr.Close < midpoint && curr.Close > prev.Open
+}
+
+// isBullishKicker checks for a red candle followed by a long green candle that gaps above the previous open.
+func isBullishKicker(prev, curr Candle, priorTrend string) bool {
+	return priorTrend == Bearish && prev.Close < prev.Open && curr.Close > curr.Open &&
+		isLongBody(prev) && isLongBody(curr) && curr.Open >= prev.Open
+}
+
+// isBearishKicker checks for a green candle followed by a long red candle that gaps below the previous open.
+func isBearishKicker(prev, curr Candle, priorTrend string) bool {
+	return priorTrend == Bullish && prev.Close > prev.Open && curr.Close < curr.Open &&
+		isLongBody(prev) && isLongBody(curr) && curr.Open <= prev.Open
+}
+
+// - additional three candle stick patterns - //
+
+// isBullishThreeInsideUp checks for a bullish harami confirmed by a third candle closing above the first open.
+func isBullishThreeInsideUp(last3 []Candle, priorTrend string) bool {
+	return len(last3) == 3 &&
+		isBullishHarami(last3[0], last3[1], priorTrend) &&
+		last3[2].Close > last3[2].Open &&
+		last3[2].Close > last3[0].Open
+}
+
+// isBearishThreeInsideDown checks for a bearish harami confirmed by a third candle closing below the first open.
+func isBearishThreeInsideDown(last3 []Candle, priorTrend string) bool {
+	return len(last3) == 3 &&
+		isBearishHarami(last3[0], last3[1], priorTrend) &&
+		last3[2].Close < last3[2].Open &&
+		last3[2].Close < last3[0].Open
+}
+
+// isBullishThreeOutsideUp checks for a bullish engulfing confirmed by a third candle closing higher.
+func isBullishThreeOutsideUp(last3 []Candle, priorTrend string) bool {
+	return len(last3) == 3 && priorTrend == Bearish &&
+		isBullishEngulfing(last3[0], last3[1]) &&
+		last3[2].Close > last3[2].Open &&
+		last3[2].Close > last3[1].Close
+}
+
+// isBearishThreeOutsideDown checks for a bearish engulfing confirmed by a third candle closing lower.
+func isBearishThreeOutsideDown(last3 []Candle, priorTrend string) bool {
+	return len(last3) == 3 && priorTrend == Bullish &&
+		isBearishEngulfing(last3[0], last3[1]) &&
+		last3[2].Close < last3[2].Open &&
+		last3[2].Close < last3[1].Close
+}
+
+// isBullishAbandonedBaby checks for a red candle, a doji gapping below it and a green candle gapping above the doji.
+func isBullishAbandonedBaby(last3 []Candle, priorTrend string) bool {
+	return len(last3) == 3 && priorTrend == Bearish &&
+		last3[0].Close < last3[0].Open &&
+		isDoji(last3[1]) &&
+		last3[1].High < last3[0].Low &&
+		last3[2].Close > last3[2].Open &&
+		last3[2].Low > last3[1].High
+}
+
+// isBearishAbandonedBaby checks for a green candle, a doji gapping above it and a red candle gapping below the doji.
+func isBearishAbandonedBaby(last3 []Candle, priorTrend string) bool {
+	return len(last3) == 3 && priorTrend == Bullish &&
+		last3[0].Close > last3[0].Open &&
+		isDoji(last3[1]) &&
+		last3[1].Low > last3[0].High &&
+		last3[2].Close < last3[2].Open &&
+		last3[2].High < last3[1].Low
+}
+
+// - five candle stick patterns - //
+
+// isBullishRisingThreeMethods checks for a long green candle, three small candles held within its range and a long green candle closing above it.
+func isBullishRisingThreeMethods(last5 []Candle, priorTrend string) bool {
+	if len(last5) != 5 || priorTrend != Bullish {
+		return false
+	}
+
+	first, last := last5[0], last5[4]
+	if !(first.Close > first.Open && isLongBody(first)) || !(last.Close > last.Open && isLongBody(last)) {
+		return false
+	}
+
+	for _, c := range last5[1:4] {
+		if candleBody(c) >= candleBody(first) || c.High > first.High || c.Low < first.Low {
+			return false
+		}
+	}
+
+	return last.Close > first.Close
+}
+
+// isBearishFallingThreeMethods checks for a long red candle, three small candles held within its range and a long red candle closing below it.
+func isBearishFallingThreeMethods(last5 []Candle, priorTrend string) bool {
+	if len(last5) != 5 || priorTrend != Bearish {
+		return false
+	}
+
+	first, last := last5[0], last5[4]
+	if !(first.Close < first.Open && isLongBody(first)) || !(last.Close < last.Open && isLongBody(last)) {
+		return false
+	}
+
+	for _, c := range last5[1:4] {
+		if candleBody(c) >= candleBody(first) || c.High > first.High || c.Low < first.Low {
+			return false
+		}
+	}
+
+	return last.Close < first.Close
+}
@@ -0,0 +1,595 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/markcheno/go-talib"
+)
+
+const (
+	SwingHigh = "high"
+	SwingLow  = "low"
+
+	PatternForming   = "forming"
+	PatternConfirmed = "confirmed"
+	PatternFailed    = "failed"
+)
+
+// SwingPoint is a local high or low on the zig-zag of a price series.
+type SwingPoint struct {
+	Index int
+	Price float64
+	Type  string
+}
+
+// ChartPattern is a swing based chart pattern with its breakout state and measured-move target.
+type ChartPattern struct {
+	Pattern   string
+	State     string
+	Direction string
+
+	Breakout     float64
+	Invalidation float64
+	Target       float64
+
+	StartIndex, EndIndex,
+	BreakoutIndex int
+}
+
+// ChartPatternConfig holds the lookbacks used for swing based pattern and zone detection. An
+// analysis fetches at least Window klines, up to 1000, so the window is never cut short.
+type ChartPatternConfig struct {
+	SwingLookback int
+	Window        int
+	Tolerance     float64
+	ZoneTolerance float64
+
+	SwingATRMultiplier float64
+}
+
+// DefaultChartPatternConfig is used when no chartpatterns section is configured.
+var DefaultChartPatternConfig = ChartPatternConfig{
+	SwingLookback: 3,
+	Window:        120,
+	Tolerance:     0.01,
+	ZoneTolerance: 0.005,
+
+	SwingATRMultiplier: 3,
+}
+
+// trendLine is a straight line through swing points, expressed as price at an index plus slope per bar.
+type trendLine struct {
+	Index int
+	Price float64
+	Slope float64
+}
+
+func (line trendLine) at(index int) float64 {
+	return line.Price + line.Slope*float64(index-line.Index)
+}
+
+// flatLine returns a horizontal line at price.
+func flatLine(index int, price float64) trendLine {
+	return trendLine{Index: index, Price: price}
+}
+
+// fitLine computes the least squares line through the given swing points.
+func fitLine(points []SwingPoint) trendLine {
+	if len(points) == 0 {
+		return trendLine{}
+	}
+	if len(points) == 1 {
+		return flatLine(points[0].Index, points[0].Price)
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, point := range points {
+		x := float64(point.Index)
+		sumX += x
+		sumY += point.Price
+		sumXY += x * point.Price
+		sumXX += x * x
+	}
+
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return flatLine(points[0].Index, sumY/n)
+	}
+
+	lineSlope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - lineSlope*sumX) / n
+	return trendLine{Index: 0, Price: intercept, Slope: lineSlope}
+}
+
+// findSwingPoints marks a bar as a swing high (low) when no bar within lookback on either side
+// trades higher (lower). Consecutive points of the same type are collapsed to the more extreme one
+// so that highs and lows alternate like a zig-zag.
+func findSwingPoints(highs, lows []float64, lookback int) (swings []SwingPoint) {
+	if lookback < 1 || len(highs) != len(lows) {
+		return
+	}
+
+	for i := lookback; i < len(highs)-lookback; i++ {
+		isHigh, isLow := true, true
+		for j := i - lookback; j <= i+lookback; j++ {
+			if j == i {
+				continue
+			}
+			if highs[j] > highs[i] {
+				isHigh = false
+			}
+			if lows[j] < lows[i] {
+				isLow = false
+			}
+		}
+
+		if isHigh {
+			swings = appendSwing(swings, SwingPoint{Index: i, Price: highs[i], Type: SwingHigh})
+		}
+		if isLow {
+			swings = appendSwing(swings, SwingPoint{Index: i, Price: lows[i], Type: SwingLow})
+		}
+	}
+	return
+}
+
+// appendSwing keeps highs and lows alternating, retaining the more extreme of two consecutive points of the same type.
+func appendSwing(swings []SwingPoint, point SwingPoint) []SwingPoint {
+	if n := len(swings); n > 0 && swings[n-1].Type == point.Type {
+		last := swings[n-1]
+		if (point.Type == SwingHigh && point.Price > last.Price) ||
+			(point.Type == SwingLow && point.Price < last.Price) {
+			swings[n-1] = point
+		}
+		return swings
+	}
+	return append(swings, point)
+}
+
+// swingSequence returns the most recent run of swings matching the given types in order.
+func swingSequence(swings []SwingPoint, types ...string) []SwingPoint {
+	for end := len(swings); end >= len(types); end-- {
+		match := true
+		for i, swingType := range types {
+			if swings[end-len(types)+i].Type != swingType {
+				match = false
+				break
+			}
+		}
+		if match {
+			return swings[end-len(types) : end]
+		}
+	}
+	return nil
+}
+
+// breakoutState walks the closes after the pattern completed. A close above upper or below lower
+// confirms the breakout in that direction unless the pattern only allows the other direction, in which
+// case the pattern failed. A confirmed breakout fails when price closes back beyond the opposite line.
+func breakoutState(closes []float64, from int, upper, lower trendLine, direction string) (state, breakDirection string, breakIndex int) {
+	state = PatternForming
+	breakIndex = -1
+
+	for i := from + 1; i < len(closes); i++ {
+		switch state {
+		case PatternForming:
+			if closes[i] > upper.at(i) {
+				if direction == Bearish {
+					return PatternFailed, Bullish, i
+				}
+				state, breakDirection, breakIndex = PatternConfirmed, Bullish, i
+			} else if closes[i] < lower.at(i) {
+				if direction == Bullish {
+					return PatternFailed, Bearish, i
+				}
+				state, breakDirection, breakIndex = PatternConfirmed, Bearish, i
+			}
+
+		case PatternConfirmed:
+			if breakDirection == Bullish && closes[i] < lower.at(i) {
+				return PatternFailed, breakDirection, breakIndex
+			}
+			if breakDirection == Bearish && closes[i] > upper.at(i) {
+				return PatternFailed, breakDirection, breakIndex
+			}
+		}
+	}
+	return
+}
+
+// newChartPattern evaluates the breakout state of a pattern bounded by upper and lower and projects
+// the measured move of height from the breakout line. Two sided patterns (direction "") only get a
+// target once price has broken out of them.
+func newChartPattern(name, direction string, closes []float64, start, end int, upper, lower trendLine, height float64) ChartPattern {
+	pattern := ChartPattern{
+		Pattern:       name,
+		Direction:     direction,
+		StartIndex:    start,
+		EndIndex:      end,
+		BreakoutIndex: -1,
+	}
+
+	state, breakDirection, breakIndex := breakoutState(closes, end, upper, lower, direction)
+	pattern.State = state
+
+	if state == PatternConfirmed || (state == PatternFailed && direction == "") {
+		pattern.Direction = breakDirection
+		pattern.BreakoutIndex = breakIndex
+	}
+
+	index := len(closes) - 1
+	if pattern.BreakoutIndex >= 0 {
+		index = pattern.BreakoutIndex
+	}
+
+	switch pattern.Direction {
+	case Bullish:
+		pattern.Breakout = TruncateFloat(upper.at(index), 8)
+		pattern.Invalidation = TruncateFloat(lower.at(index), 8)
+		pattern.Target = TruncateFloat(pattern.Breakout+height, 8)
+	case Bearish:
+		pattern.Breakout = TruncateFloat(lower.at(index), 8)
+		pattern.Invalidation = TruncateFloat(upper.at(index), 8)
+		pattern.Target = TruncateFloat(pattern.Breakout-height, 8)
+	}
+
+	if pattern.Target < 0 {
+		pattern.Target = 0
+	}
+	return pattern
+}
+
+// isWithinTolerance checks that every price is within tolerance (a fraction) of their average.
+func isWithinTolerance(tolerance float64, prices ...float64) bool {
+	if len(prices) == 0 {
+		return false
+	}
+
+	var average float64
+	for _, price := range prices {
+		average += price
+	}
+	average /= float64(len(prices))
+
+	for _, price := range prices {
+		if !isApproxEqual(price, average, average*tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// - horizontal reversal patterns - //
+
+// swingDoubleTop looks for two equal highs around a low, the low being the neckline.
+func swingDoubleTop(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingHigh, SwingLow, SwingHigh)
+	if points == nil || !isWithinTolerance(cfg.Tolerance, points[0].Price, points[2].Price) {
+		return
+	}
+
+	peak := math.Max(points[0].Price, points[2].Price)
+	neckline := points[1].Price
+	return newChartPattern("Bearish: Double Top", Bearish, closes, points[0].Index, points[2].Index,
+		flatLine(points[2].Index, peak), flatLine(points[1].Index, neckline), peak-neckline), true
+}
+
+// swingDoubleBottom looks for two equal lows around a high, the high being the neckline.
+func swingDoubleBottom(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingLow, SwingHigh, SwingLow)
+	if points == nil || !isWithinTolerance(cfg.Tolerance, points[0].Price, points[2].Price) {
+		return
+	}
+
+	trough := math.Min(points[0].Price, points[2].Price)
+	neckline := points[1].Price
+	return newChartPattern("Bullish: Double Bottom", Bullish, closes, points[0].Index, points[2].Index,
+		flatLine(points[1].Index, neckline), flatLine(points[2].Index, trough), neckline-trough), true
+}
+
+// swingTripleTop looks for three equal highs, the lower of the two lows between them being the neckline.
+func swingTripleTop(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingHigh, SwingLow, SwingHigh, SwingLow, SwingHigh)
+	if points == nil || !isWithinTolerance(cfg.Tolerance, points[0].Price, points[2].Price, points[4].Price) {
+		return
+	}
+
+	peak := math.Max(points[0].Price, math.Max(points[2].Price, points[4].Price))
+	neckline := math.Min(points[1].Price, points[3].Price)
+	return newChartPattern("Bearish: Triple Top", Bearish, closes, points[0].Index, points[4].Index,
+		flatLine(points[4].Index, peak), flatLine(points[3].Index, neckline), peak-neckline), true
+}
+
+// swingTripleBottom looks for three equal lows, the higher of the two highs between them being the neckline.
+func swingTripleBottom(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingLow, SwingHigh, SwingLow, SwingHigh, SwingLow)
+	if points == nil || !isWithinTolerance(cfg.Tolerance, points[0].Price, points[2].Price, points[4].Price) {
+		return
+	}
+
+	trough := math.Min(points[0].Price, math.Min(points[2].Price, points[4].Price))
+	neckline := math.Max(points[1].Price, points[3].Price)
+	return newChartPattern("Bullish: Triple Bottom", Bullish, closes, points[0].Index, points[4].Index,
+		flatLine(points[3].Index, neckline), flatLine(points[4].Index, trough), neckline-trough), true
+}
+
+// swingHeadAndShoulders looks for a higher head between two equal shoulders, with the neckline drawn through the two lows.
+func swingHeadAndShoulders(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingHigh, SwingLow, SwingHigh, SwingLow, SwingHigh)
+	if points == nil {
+		return
+	}
+
+	leftShoulder, head, rightShoulder := points[0], points[2], points[4]
+	if head.Price <= leftShoulder.Price || head.Price <= rightShoulder.Price ||
+		!isWithinTolerance(cfg.Tolerance*2, leftShoulder.Price, rightShoulder.Price) {
+		return
+	}
+
+	neckline := fitLine([]SwingPoint{points[1], points[3]})
+	return newChartPattern("Bearish: Head and Shoulders", Bearish, closes, leftShoulder.Index, rightShoulder.Index,
+		flatLine(head.Index, head.Price), neckline, head.Price-neckline.at(head.Index)), true
+}
+
+// swingInverseHeadAndShoulders looks for a lower head between two equal shoulders, with the neckline drawn through the two highs.
+func swingInverseHeadAndShoulders(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingLow, SwingHigh, SwingLow, SwingHigh, SwingLow)
+	if points == nil {
+		return
+	}
+
+	leftShoulder, head, rightShoulder := points[0], points[2], points[4]
+	if head.Price >= leftShoulder.Price || head.Price >= rightShoulder.Price ||
+		!isWithinTolerance(cfg.Tolerance*2, leftShoulder.Price, rightShoulder.Price) {
+		return
+	}
+
+	neckline := fitLine([]SwingPoint{points[1], points[3]})
+	return newChartPattern("Bullish: Head and Shoulders", Bullish, closes, leftShoulder.Index, rightShoulder.Index,
+		neckline, flatLine(head.Index, head.Price), neckline.at(head.Index)-head.Price), true
+}
+
+// swingCupAndHandle looks for two equal rims around a rounded low, followed by a shallow handle
+// that holds in the upper half of the cup. The rim is the breakout level and the cup depth the target.
+func swingCupAndHandle(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	points := swingSequence(swings, SwingHigh, SwingLow, SwingHigh, SwingLow)
+	if points == nil {
+		return
+	}
+
+	leftRim, bottom, rightRim, handle := points[0], points[1], points[2], points[3]
+	if !isWithinTolerance(cfg.Tolerance*2, leftRim.Price, rightRim.Price) {
+		return
+	}
+
+	rim := math.Max(leftRim.Price, rightRim.Price)
+	depth := rim - bottom.Price
+	if depth <= 0 || handle.Price <= bottom.Price+depth/2 {
+		return
+	}
+
+	// the handle has to be shorter than the cup it hangs from
+	if handle.Index-rightRim.Index >= rightRim.Index-leftRim.Index {
+		return
+	}
+
+	return newChartPattern("Bullish: Cup and Handle", Bullish, closes, leftRim.Index, handle.Index,
+		flatLine(rightRim.Index, rim), flatLine(handle.Index, handle.Price), depth), true
+}
+
+// - trendline patterns - //
+
+// swingTrendlinePattern fits lines through the last swing highs and lows and classifies them as
+// channels, triangles, wedges, flags or pennants depending on their slopes and convergence.
+func swingTrendlinePattern(closes []float64, swings []SwingPoint, cfg ChartPatternConfig) (pattern ChartPattern, found bool) {
+	if len(swings) < 4 {
+		return
+	}
+
+	recent := swings[len(swings)-4:]
+	if len(swings) >= 6 {
+		recent = swings[len(swings)-6:]
+	}
+
+	var highs, lows []SwingPoint
+	for _, swing := range recent {
+		if swing.Type == SwingHigh {
+			highs = append(highs, swing)
+		} else {
+			lows = append(lows, swing)
+		}
+	}
+	if len(highs) < 2 || len(lows) < 2 {
+		return
+	}
+
+	upper, lower := fitLine(highs), fitLine(lows)
+	start, end := recent[0].Index, recent[len(recent)-1].Index
+	span := float64(end - start)
+	startWidth := upper.at(start) - lower.at(start)
+	endWidth := upper.at(end) - lower.at(end)
+	if span <= 0 || startWidth <= 0 || endWidth <= 0 {
+		return
+	}
+
+	price := (upper.at(end) + lower.at(end)) / 2
+	slopeDirection := func(line trendLine) int {
+		if math.Abs(line.Slope)*span <= cfg.Tolerance*price {
+			return 0
+		}
+		if line.Slope > 0 {
+			return 1
+		}
+		return -1
+	}
+	upperDirection, lowerDirection := slopeDirection(upper), slopeDirection(lower)
+
+	parallel := math.Abs(endWidth-startWidth) <= 0.25*startWidth
+	converging := endWidth < 0.75*startWidth
+
+	// a strong move into the pattern turns small consolidations into flags and pennants
+	if first := len(swings) - len(recent); first > 0 {
+		poleStart := swings[first-1]
+		poleHeight := math.Abs(recent[0].Price - poleStart.Price)
+		poleDirection := Bullish
+		if recent[0].Price < poleStart.Price {
+			poleDirection = Bearish
+		}
+
+		if poleHeight >= 2*startWidth {
+			switch {
+			case parallel && ((poleDirection == Bullish && upperDirection <= 0) || (poleDirection == Bearish && upperDirection >= 0)):
+				return newChartPattern("Continuation: Flag", poleDirection, closes, poleStart.Index, end, upper, lower, poleHeight), true
+			case converging && upperDirection < 0 && lowerDirection > 0:
+				return newChartPattern("Continuation: Pennant", poleDirection, closes, poleStart.Index, end, upper, lower, poleHeight), true
+			}
+		}
+	}
+
+	switch {
+	case parallel && upperDirection > 0 && lowerDirection > 0:
+		return newChartPattern("Continuation: Ascending Channel", "", closes, start, end, upper, lower, startWidth), true
+	case parallel && upperDirection < 0 && lowerDirection < 0:
+		return newChartPattern("Continuation: Descending Channel", "", closes, start, end, upper, lower, startWidth), true
+	case parallel && upperDirection == 0 && lowerDirection == 0:
+		return newChartPattern("Continuation: Horizontal Channel", "", closes, start, end, upper, lower, startWidth), true
+	case converging && upperDirection > 0 && lowerDirection > 0:
+		return newChartPattern("Bearish: Rising Wedge", Bearish, closes, start, end, upper, lower, startWidth), true
+	case converging && upperDirection < 0 && lowerDirection < 0:
+		return newChartPattern("Bullish: Falling Wedge", Bullish, closes, start, end, upper, lower, startWidth), true
+	case converging && upperDirection == 0 && lowerDirection > 0:
+		return newChartPattern("Neutral: Ascending Triangle", "", closes, start, end, upper, lower, startWidth), true
+	case converging && upperDirection < 0 && lowerDirection == 0:
+		return newChartPattern("Neutral: Descending Triangle", "", closes, start, end, upper, lower, startWidth), true
+	case converging && upperDirection < 0 && lowerDirection > 0:
+		return newChartPattern("Neutral: Symmetrical Triangle", "", closes, start, end, upper, lower, startWidth), true
+	}
+	return
+}
+
+// DetectSwingChartPatterns finds swing based chart patterns over the last cfg.Window bars.
+// Each pattern reports whether it is still forming, has confirmed a breakout or failed.
+func DetectSwingChartPatterns(data MarketData, cfg ChartPatternConfig) (patterns []ChartPattern) {
+	if cfg.SwingLookback < 1 {
+		cfg.SwingLookback = DefaultChartPatternConfig.SwingLookback
+	}
+	if cfg.Window < 1 {
+		cfg.Window = DefaultChartPatternConfig.Window
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = DefaultChartPatternConfig.Tolerance
+	}
+
+	if len(data.Close) != len(data.High) || len(data.Close) != len(data.Low) {
+		return
+	}
+
+	offset := 0
+	if len(data.Close) > cfg.Window {
+		offset = len(data.Close) - cfg.Window
+	}
+	closes, highs, lows := data.Close[offset:], data.High[offset:], data.Low[offset:]
+
+	swings := findSwingPoints(highs, lows, cfg.SwingLookback)
+	if len(swings) < 3 {
+		return
+	}
+
+	detectors := []func([]float64, []SwingPoint, ChartPatternConfig) (ChartPattern, bool){
+		swingHeadAndShoulders,
+		swingInverseHeadAndShoulders,
+		swingTripleTop,
+		swingTripleBottom,
+		swingDoubleTop,
+		swingDoubleBottom,
+		swingCupAndHandle,
+		swingTrendlinePattern,
+	}
+
+	for _, detector := range detectors {
+		if pattern, found := detector(closes, swings, cfg); found {
+			pattern.StartIndex += offset
+			pattern.EndIndex += offset
+			if pattern.BreakoutIndex >= 0 {
+				pattern.BreakoutIndex += offset
+			}
+			patterns = append(patterns, pattern)
+		}
+	}
+	return
+}
+
+// swingThreshold returns the minimum price move for a zig-zag reversal: the latest ATR scaled by
+// cfg.SwingATRMultiplier, or cfg.Tolerance of the last close while there is too little data for an ATR.
+func swingThreshold(data MarketData, cfg ChartPatternConfig) float64 {
+	if cfg.SwingATRMultiplier <= 0 {
+		cfg.SwingATRMultiplier = DefaultChartPatternConfig.SwingATRMultiplier
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = DefaultChartPatternConfig.Tolerance
+	}
+
+	atrPeriod := 14
+	if len(data.Close) > atrPeriod+1 {
+		atr := talib.Atr(data.High, data.Low, data.Close, atrPeriod)
+		if value := atr[len(atr)-1]; value > 0 {
+			return value * cfg.SwingATRMultiplier
+		}
+	}
+	return data.Close[len(data.Close)-1] * cfg.Tolerance
+}
+
+// findZigZagSwings returns the confirmed zig-zag pivots, a pivot being confirmed once price has
+// reversed from it by at least threshold, followed by the running extreme of the current leg.
+func findZigZagSwings(highs, lows []float64, threshold float64) (swings []SwingPoint) {
+	if len(highs) == 0 || len(highs) != len(lows) || threshold <= 0 {
+		return
+	}
+
+	direction := ""
+	candidateHigh := SwingPoint{Index: 0, Price: highs[0], Type: SwingHigh}
+	candidateLow := SwingPoint{Index: 0, Price: lows[0], Type: SwingLow}
+
+	for i := 1; i < len(highs); i++ {
+		if highs[i] > candidateHigh.Price && direction != Bearish {
+			candidateHigh = SwingPoint{Index: i, Price: highs[i], Type: SwingHigh}
+		}
+		if lows[i] < candidateLow.Price && direction != Bullish {
+			candidateLow = SwingPoint{Index: i, Price: lows[i], Type: SwingLow}
+		}
+
+		switch direction {
+		case Bullish:
+			if candidateHigh.Price-lows[i] >= threshold {
+				swings = append(swings, candidateHigh)
+				direction = Bearish
+				candidateLow = SwingPoint{Index: i, Price: lows[i], Type: SwingLow}
+			}
+		case Bearish:
+			if highs[i]-candidateLow.Price >= threshold {
+				swings = append(swings, candidateLow)
+				direction = Bullish
+				candidateHigh = SwingPoint{Index: i, Price: highs[i], Type: SwingHigh}
+			}
+		default:
+			if candidateHigh.Price-lows[i] >= threshold && candidateHigh.Index < i {
+				swings = append(swings, candidateHigh)
+				direction = Bearish
+				candidateLow = SwingPoint{Index: i, Price: lows[i], Type: SwingLow}
+			} else if highs[i]-candidateLow.Price >= threshold && candidateLow.Index < i {
+				swings = append(swings, candidateLow)
+				direction = Bullish
+				candidateHigh = SwingPoint{Index: i, Price: highs[i], Type: SwingHigh}
+			}
+		}
+	}
+
+	switch direction {
+	case Bullish:
+		swings = append(swings, candidateHigh)
+	case Bearish:
+		swings = append(swings, candidateLow)
+	}
+	return
+}
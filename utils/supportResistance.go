@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"sort"
+)
+
+const (
+	ZoneSupport    = "support"
+	ZoneResistance = "resistance"
+)
+
+// timeframeWeights ranks how much a higher timeframe contributes compared to a lower one.
+var timeframeWeights = map[string]int{
+	"1m": 5, "5m": 10, "15m": 15, "1h": 25, "4h": 30, "1d": 45, "3d": 50, "1w": 55, "1M": 60,
+}
+
+// SRZone is a price band where swing highs and lows have clustered.
+type SRZone struct {
+	Type       string
+	Price      float64
+	Low, High  float64
+	Touches    int
+	Strength   float64
+	Timeframes []string
+}
+
+// PivotPoints holds the classic, fibonacci and camarilla pivots projected from a completed candle.
+type PivotPoints struct {
+	Classic   map[string]float64
+	Fibonacci map[string]float64
+	Camarilla map[string]float64
+}
+
+// zonePoint is a swing price together with the weight of the timeframe it came from.
+type zonePoint struct {
+	Price     float64
+	Weight    float64
+	Timeframe string
+}
+
+// clusterZones groups prices that sit within tolerance (a fraction) of the running cluster average.
+func clusterZones(points []zonePoint, tolerance, price float64) (zones []SRZone) {
+	if len(points) == 0 {
+		return
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Price < points[j].Price
+	})
+
+	var cluster []zonePoint
+	var sum float64
+	flush := func() {
+		if len(cluster) == 0 {
+			return
+		}
+
+		zone := SRZone{
+			Price:   TruncateFloat(sum/float64(len(cluster)), 8),
+			Low:     cluster[0].Price,
+			High:    cluster[len(cluster)-1].Price,
+			Touches: len(cluster),
+		}
+
+		timeframes := make(map[string]bool)
+		for _, point := range cluster {
+			zone.Strength += point.Weight
+			if point.Timeframe != "" && !timeframes[point.Timeframe] {
+				timeframes[point.Timeframe] = true
+				zone.Timeframes = append(zone.Timeframes, point.Timeframe)
+			}
+		}
+		zone.Strength = TruncateFloat(zone.Strength, 3)
+
+		zone.Type = ZoneResistance
+		if zone.Price < price {
+			zone.Type = ZoneSupport
+		}
+		zones = append(zones, zone)
+	}
+
+	for _, point := range points {
+		if len(cluster) > 0 {
+			average := sum / float64(len(cluster))
+			if !isApproxEqual(point.Price, average, average*tolerance) {
+				flush()
+				cluster, sum = nil, 0
+			}
+		}
+		cluster = append(cluster, point)
+		sum += point.Price
+	}
+	flush()
+
+	sort.SliceStable(zones, func(i, j int) bool {
+		return zones[i].Strength > zones[j].Strength
+	})
+	return
+}
+
+// findSupportResistanceZones clusters the swing highs and lows of a single timeframe into zones.
+func findSupportResistanceZones(data MarketData, timeframe string, cfg ChartPatternConfig) []SRZone {
+	if len(data.Close) == 0 {
+		return nil
+	}
+
+	if cfg.SwingLookback < 1 {
+		cfg.SwingLookback = DefaultChartPatternConfig.SwingLookback
+	}
+	if cfg.ZoneTolerance <= 0 {
+		cfg.ZoneTolerance = DefaultChartPatternConfig.ZoneTolerance
+	}
+
+	var points []zonePoint
+	for _, swing := range findSwingPoints(data.High, data.Low, cfg.SwingLookback) {
+		points = append(points, zonePoint{Price: swing.Price, Weight: 1, Timeframe: timeframe})
+	}
+	return clusterZones(points, cfg.ZoneTolerance, data.Close[len(data.Close)-1])
+}
+
+// MergeTimeframeZones clusters the zones of several timeframes together, so a level that
+// holds on the 1h and the 1d scores higher than one seen on a single timeframe. The classic
+// pivots of each timeframe count as a touch, so a swing zone at a pivot scores higher and two
+// timeframes' pivots that agree make a zone of their own.
+func MergeTimeframeZones(intervals map[string]Summary, price float64, tolerance float64) []SRZone {
+	if tolerance <= 0 {
+		tolerance = DefaultChartPatternConfig.ZoneTolerance
+	}
+
+	var points []zonePoint
+	for timeframe, interval := range intervals {
+		weight := float64(timeframeWeights[timeframe])
+		if weight == 0 {
+			weight = 1
+		}
+
+		for _, zone := range interval.Zones {
+			for touch := 0; touch < zone.Touches; touch++ {
+				points = append(points, zonePoint{Price: zone.Price, Weight: weight, Timeframe: timeframe})
+			}
+		}
+
+		for _, level := range interval.Pivots.Classic {
+			if level > 0 {
+				points = append(points, zonePoint{Price: level, Weight: weight, Timeframe: timeframe})
+			}
+		}
+	}
+	return clusterZones(points, tolerance, price)
+}
+
+// NearestZone returns the strongest zone of zoneType that price is trading in or within
+// tolerance of, the bool reporting whether one was found.
+func NearestZone(zones []SRZone, price float64, zoneType string, tolerance float64) (nearest SRZone, found bool) {
+	for _, zone := range zones {
+		if zone.Type != zoneType {
+			continue
+		}
+
+		if price < zone.Low*(1-tolerance) || price > zone.High*(1+tolerance) {
+			continue
+		}
+
+		if !found || zone.Strength > nearest.Strength {
+			nearest, found = zone, true
+		}
+	}
+	return
+}
+
+// calculatePivotPoints projects the pivots of the next period from a completed candle.
+// The pivots of the 1d and 1w summaries are therefore the daily and weekly pivots.
+func calculatePivotPoints(c Candle) PivotPoints {
+	if c.High == 0 || c.Low == 0 {
+		return PivotPoints{}
+	}
+
+	pivot := (c.High + c.Low + c.Close) / 3
+	spread := c.High - c.Low
+
+	return PivotPoints{
+		Classic: map[string]float64{
+			"P":  TruncateFloat(pivot, 8),
+			"R1": TruncateFloat(2*pivot-c.Low, 8),
+			"R2": TruncateFloat(pivot+spread, 8),
+			"R3": TruncateFloat(c.High+2*(pivot-c.Low), 8),
+			"S1": TruncateFloat(2*pivot-c.High, 8),
+			"S2": TruncateFloat(pivot-spread, 8),
+			"S3": TruncateFloat(c.Low-2*(c.High-pivot), 8),
+		},
+		Fibonacci: map[string]float64{
+			"P":  TruncateFloat(pivot, 8),
+			"R1": TruncateFloat(pivot+spread*0.382, 8),
+			"R2": TruncateFloat(pivot+spread*0.618, 8),
+			"R3": TruncateFloat(pivot+spread, 8),
+			"S1": TruncateFloat(pivot-spread*0.382, 8),
+			"S2": TruncateFloat(pivot-spread*0.618, 8),
+			"S3": TruncateFloat(pivot-spread, 8),
+		},
+		Camarilla: map[string]float64{
+			"P":  TruncateFloat(pivot, 8),
+			"R1": TruncateFloat(c.Close+spread*1.1/12, 8),
+			"R2": TruncateFloat(c.Close+spread*1.1/6, 8),
+			"R3": TruncateFloat(c.Close+spread*1.1/4, 8),
+			"R4": TruncateFloat(c.Close+spread*1.1/2, 8),
+			"S1": TruncateFloat(c.Close-spread*1.1/12, 8),
+			"S2": TruncateFloat(c.Close-spread*1.1/6, 8),
+			"S3": TruncateFloat(c.Close-spread*1.1/4, 8),
+			"S4": TruncateFloat(c.Close-spread*1.1/2, 8),
+		},
+	}
+}
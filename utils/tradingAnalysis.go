@@ -41,8 +41,9 @@ type trendAnalysis struct {
 
 // Summary contains the final analysis report.
 type SummaryPattern struct {
-	Chart  string
-	Candle string
+	Chart      string
+	Candle     string
+	PriorTrend string
 }
 type Summary struct {
 	Timeframe         string
@@ -54,6 +55,11 @@ type Summary struct {
 	SMA20             trendAnalysis
 	SMA50             trendAnalysis
 	RetracementLevels map[string]float64
+	Fibonacci         FibonacciLevels
+	ChartPatterns     []ChartPattern
+	Zones             []SRZone
+	VolumeProfile     VolumeProfile
+	Pivots            PivotPoints
 	Candle            Candle
 	PrevCandle        Candle
 }
@@ -96,8 +102,27 @@ func analyzeTrend(data MarketData, period int) trendAnalysis {
 	}
 }
 
-// calculateFibonacciRetracement computes retracement levels.
-func calculateFibonacciRetracement(high, low float64) map[string]float64 {
+// FibonacciLevels holds the retracement and extension levels of the swing they are anchored on.
+// Direction is Bullish for a swing up (low to high) and Bearish for a swing down (high to low).
+type FibonacciLevels struct {
+	Direction    string
+	From, To     SwingPoint
+	Retracements map[string]float64
+	Extensions   map[string]float64
+}
+
+// calculateFibonacciRetracement computes retracement levels, measured back from the end of the swing.
+func calculateFibonacciRetracement(high, low float64, direction string) map[string]float64 {
+	if direction == Bearish {
+		return map[string]float64{
+			"0.236": TruncateFloat(low+(high-low)*0.236, 8),
+			"0.382": TruncateFloat(low+(high-low)*0.382, 8),
+			"0.500": TruncateFloat(low+(high-low)*0.500, 8),
+			"0.618": TruncateFloat(low+(high-low)*0.618, 8),
+			"0.786": TruncateFloat(low+(high-low)*0.786, 8),
+		}
+	}
+
 	levels := map[string]float64{
 		"0.236": TruncateFloat(high-(high-low)*0.236, 8),
 		"0.382": TruncateFloat(high-(high-low)*0.382, 8),
@@ -108,6 +133,71 @@ func calculateFibonacciRetracement(high, low float64) map[string]float64 {
 	return levels
 }
 
+// calculateFibonacciExtension projects the swing beyond its end for take-profit targets.
+func calculateFibonacciExtension(high, low float64, direction string) map[string]float64 {
+	if direction == Bearish {
+		return map[string]float64{
+			"1.272": TruncateFloat(high-(high-low)*1.272, 8),
+			"1.618": TruncateFloat(high-(high-low)*1.618, 8),
+			"2.618": TruncateFloat(high-(high-low)*2.618, 8),
+		}
+	}
+
+	return map[string]float64{
+		"1.272": TruncateFloat(low+(high-low)*1.272, 8),
+		"1.618": TruncateFloat(low+(high-low)*1.618, 8),
+		"2.618": TruncateFloat(low+(high-low)*2.618, 8),
+	}
+}
+
+// anchoredFibonacci anchors the fibonacci levels on the most recent significant swing, that is
+// the last confirmed zig-zag pivot and the running extreme of the leg after it. Without a pivot
+// it falls back to the high and low of the window, the direction following whichever came last.
+func anchoredFibonacci(data MarketData, fallback trendAnalysis, cfg ChartPatternConfig) (fibonacci FibonacciLevels) {
+	if len(data.Close) == 0 {
+		return
+	}
+
+	var from, to SwingPoint
+	swings := findZigZagSwings(data.High, data.Low, swingThreshold(data, cfg))
+	if len(swings) >= 2 {
+		from, to = swings[len(swings)-2], swings[len(swings)-1]
+	} else {
+		// the window's extremes are its most recent bars at those prices
+		highIndex, lowIndex := -1, -1
+		for index := len(data.Close) - 1; index >= 0 && (highIndex < 0 || lowIndex < 0); index-- {
+			if highIndex < 0 && data.High[index] == fallback.Resistance {
+				highIndex = index
+			}
+			if lowIndex < 0 && data.Low[index] == fallback.Support {
+				lowIndex = index
+			}
+		}
+
+		from = SwingPoint{Index: lowIndex, Price: fallback.Support, Type: SwingLow}
+		to = SwingPoint{Index: highIndex, Price: fallback.Resistance, Type: SwingHigh}
+		if lowIndex > highIndex {
+			from, to = to, from
+		}
+	}
+
+	fibonacci.From, fibonacci.To = from, to
+	fibonacci.Direction = Bullish
+	high, low := to.Price, from.Price
+	if to.Type == SwingLow {
+		fibonacci.Direction = Bearish
+		high, low = from.Price, to.Price
+	}
+
+	if high == 0 || low == 0 {
+		return
+	}
+
+	fibonacci.Retracements = calculateFibonacciRetracement(high, low, fibonacci.Direction)
+	fibonacci.Extensions = calculateFibonacciExtension(high, low, fibonacci.Direction)
+	return
+}
+
 // CalculateSmoothedRSI computes a smoothed RSI using SMA or EMA.
 func CalculateSmoothedRSI(closePrices []float64, rsiPeriod int, smoothingPeriod int) float64 {
 	if len(closePrices) < 2 {
@@ -166,8 +256,36 @@ func calculateBollingerBands(closePrices []float64, period int, stdDev float64)
 	}
 }
 
-// identifyCandlestickPattern detects candlestick patterns
-func identifyCandlestickPattern(candles []Candle) string {
+// priorCandleTrend returns the SMA trend that was in place before the last lookback
+// candles, giving reversal and continuation candlestick patterns their context.
+func priorCandleTrend(data MarketData, period, lookback int) string {
+	end := len(data.Close) - lookback
+	if period < 2 || end <= period {
+		return Neutral
+	}
+
+	prior := MarketData{
+		Close: data.Close[:end],
+		High:  data.High[:end],
+		Low:   data.Low[:end],
+		Open:  data.Open[:end],
+	}
+
+	analysis := analyzeTrend(prior, period)
+	lastClose := prior.Close[end-1]
+	switch {
+	case analysis.Entry == 0:
+		return Neutral
+	case lastClose < analysis.Entry:
+		return Bearish
+	case lastClose > analysis.Entry:
+		return Bullish
+	}
+	return Neutral
+}
+
+// identifyCandlestickPattern detects candlestick patterns, priorTrend being the trend that preceded them
+func identifyCandlestickPattern(candles []Candle, priorTrend string) string {
 	if len(candles) < 4 {
 		return "Candles less than 4"
 	}
@@ -212,22 +330,22 @@ func identifyCandlestickPattern(candles []Candle) string {
 	}
 
 	// Bullish Hammer
-	if isBullishHammer(latest) {
+	if isBullishHammer(latest, priorTrend) {
 		return "Bullish: Hammer"
 	}
 
 	// Bullish Inverted Hammer
-	if isBullishInvertedHammer(latest) {
+	if isBullishInvertedHammer(latest, priorTrend) {
 		return "Bullish: Inverted Hammer"
 	}
 
 	// Bearish: Hanging Man
-	if isBearishHangingMan(latest) {
+	if isBearishHangingMan(latest, priorTrend) {
 		return "Bearish: Hanging Man"
 	}
 
 	// Bearish: Shooting Star
-	if isBearishShootingStar(latest) {
+	if isBearishShootingStar(latest, priorTrend) {
 		return "Bearish: Shooting Star"
 	}
 
@@ -241,6 +359,16 @@ func identifyCandlestickPattern(candles []Candle) string {
 		return "Bearish: Spinning Top"
 	}
 
+	// Bullish Belt Hold
+	if isBullishBeltHold(latest, priorTrend) {
+		return "Bullish: Belt Hold"
+	}
+
+	// Bearish Belt Hold
+	if isBearishBeltHold(latest, priorTrend) {
+		return "Bearish: Belt Hold"
+	}
+
 	// - two candle stick patterns - //
 
 	// Bullish Engulfing
@@ -263,6 +391,46 @@ func identifyCandlestickPattern(candles []Candle) string {
 		return "Bearish: Tweezer Tops"
 	}
 
+	// Bullish Kicker
+	if isBullishKicker(penultimate, latest, priorTrend) {
+		return "Bullish: Kicker"
+	}
+
+	// Bearish Kicker
+	if isBearishKicker(penultimate, latest, priorTrend) {
+		return "Bearish: Kicker"
+	}
+
+	// Bullish Piercing Line
+	if isBullishPiercingLine(penultimate, latest, priorTrend) {
+		return "Bullish: Piercing Line"
+	}
+
+	// Bearish Dark Cloud Cover
+	if isBearishDarkCloudCover(penultimate, latest, priorTrend) {
+		return "Bearish: Dark Cloud Cover"
+	}
+
+	// Bullish Harami Cross
+	if isBullishHaramiCross(penultimate, latest, priorTrend) {
+		return "Bullish: Harami Cross"
+	}
+
+	// Bearish Harami Cross
+	if isBearishHaramiCross(penultimate, latest, priorTrend) {
+		return "Bearish: Harami Cross"
+	}
+
+	// Bullish Harami
+	if isBullishHarami(penultimate, latest, priorTrend) {
+		return "Bullish: Harami"
+	}
+
+	// Bearish Harami
+	if isBearishHarami(penultimate, latest, priorTrend) {
+		return "Bearish: Harami"
+	}
+
 	// - three candle stick patterns - //
 	// Bullish Deliberation (Variation of Three White Soldiers)
 	if isBullishDeliberation(candles[len(candles)-3:]) {
@@ -285,15 +453,45 @@ func identifyCandlestickPattern(candles []Candle) string {
 	}
 
 	// Bearish Evening Star
-	if isBearishEveningStar(candles[len(candles)-3:]) {
+	if isBearishEveningStar(candles[len(candles)-3:], priorTrend) {
 		return "Bearish: Evening Star"
 	}
 
 	// Bullish Morning Star
-	if isBullishMorningStar(candles[len(candles)-3:]) {
+	if isBullishMorningStar(candles[len(candles)-3:], priorTrend) {
 		return "Bullish: Morning Star"
 	}
 
+	// Bullish Abandoned Baby
+	if isBullishAbandonedBaby(candles[len(candles)-3:], priorTrend) {
+		return "Bullish: Abandoned Baby"
+	}
+
+	// Bearish Abandoned Baby
+	if isBearishAbandonedBaby(candles[len(candles)-3:], priorTrend) {
+		return "Bearish: Abandoned Baby"
+	}
+
+	// Bullish Three Inside Up
+	if isBullishThreeInsideUp(candles[len(candles)-3:], priorTrend) {
+		return "Bullish: Three Inside Up"
+	}
+
+	// Bearish Three Inside Down
+	if isBearishThreeInsideDown(candles[len(candles)-3:], priorTrend) {
+		return "Bearish: Three Inside Down"
+	}
+
+	// Bullish Three Outside Up
+	if isBullishThreeOutsideUp(candles[len(candles)-3:], priorTrend) {
+		return "Bullish: Three Outside Up"
+	}
+
+	// Bearish Three Outside Down
+	if isBearishThreeOutsideDown(candles[len(candles)-3:], priorTrend) {
+		return "Bearish: Three Outside Down"
+	}
+
 	// - four candle stick patterns - //
 	// Bearish Concealing Baby Swallow
 	if isBearishConcealingBabySwallow(candles[len(candles)-4:]) {
@@ -310,6 +508,19 @@ func identifyCandlestickPattern(candles []Candle) string {
 		return "Bullish: Three Line Strike"
 	}
 
+	// - five candle stick patterns - //
+	if len(candles) >= 5 {
+		// Bullish Rising Three Methods
+		if isBullishRisingThreeMethods(candles[len(candles)-5:], priorTrend) {
+			return "Bullish: Rising Three Methods"
+		}
+
+		// Bearish Falling Three Methods
+		if isBearishFallingThreeMethods(candles[len(candles)-5:], priorTrend) {
+			return "Bearish: Falling Three Methods"
+		}
+	}
+
 	return "?"
 }
 
@@ -430,13 +641,10 @@ func TimeframeTrends(intervals map[string]Summary) string {
 	trendName := ""
 	maxScore := 0
 	totalScore := 0
-	timeWeights := map[string]int{
-		"1m": 5, "5m": 10, "15m": 15, "1h": 25, "4h": 30, "1d": 45, "3d": 50, "1w": 55, "1M": 60,
-	}
 	// "1m": 5, "3m": 5, "5m": 10, "15m": 15, "30m": 20, "1h": 25, "4h": 30, "6h": 35, "12h": 40, "1d": 45, "3d": 50,
 
 	for timeframe, interval := range intervals {
-		multiplier := timeWeights[timeframe]
+		multiplier := timeframeWeights[timeframe]
 		trendName = OverallTrend(interval.SMA10.Entry, interval.SMA20.Entry, interval.SMA50.Entry, interval.Candle.Close)
 		trendScore := 0
 		if trendName == Bullish {
@@ -519,6 +727,7 @@ func TradingSummary(pair, timeframe string, data MarketData) (Summary, error) {
 
 	chartPattern := ""
 	candlePattern := ""
+	priorTrend := Neutral
 
 	if len(data.Close) >= period20 && len(data.Close) > 3 {
 		candleArray := []Candle{}
@@ -547,13 +756,14 @@ func TradingSummary(pair, timeframe string, data MarketData) (Summary, error) {
 		if chartPattern == "?" {
 			chartPattern = detectChartPatterns(lastClose[:len(lastClose)-1], lastHigh[:len(lastHigh)-1], lastLow[:len(lastLow)-1], lastOpen[:len(lastOpen)-1])
 		}
-		candlePattern = identifyCandlestickPattern(candleArray)
+		// the forming candle plus the longest (five candle) pattern
+		priorTrend = priorCandleTrend(data, period10, 6)
+		candlePattern = identifyCandlestickPattern(candleArray, priorTrend)
 		if candlePattern == "?" {
-			candlePattern = identifyCandlestickPattern(candleArray[:len(candleArray)-1])
+			candlePattern = identifyCandlestickPattern(candleArray[:len(candleArray)-1], priorTrend)
 		}
 	}
 
-	var dataLow, dataHigh float64
 	var currentCandle, prevCandle Candle
 	if len(data.Close) > 1 {
 		currentCandle.Close = data.Close[len(data.Close)-1]
@@ -569,28 +779,29 @@ func TradingSummary(pair, timeframe string, data MarketData) (Summary, error) {
 		prevCandle.Open = data.Open[len(data.Open)-2]
 	}
 
-	if analysis20.Support > 0 && analysis20.Resistance > 0 {
-		dataLow = analysis20.Support
-		dataHigh = analysis20.Resistance
-	}
+	fibonacci := anchoredFibonacci(data, analysis20, Config.ChartPatterns)
 
 	trendName := OverallTrend(analysis10.Entry, analysis20.Entry, analysis50.Entry, currentCandle.Close)
 	return Summary{
 		Timeframe: timeframe,
 		Trend:     trendName,
 		Pattern: SummaryPattern{
-			Chart:  chartPattern,
-			Candle: candlePattern,
+			Chart:      chartPattern,
+			Candle:     candlePattern,
+			PriorTrend: priorTrend,
 		},
-		Candle:         currentCandle,
-		PrevCandle:     prevCandle,
-		SMA10:          analysis10,
-		SMA20:          analysis20,
-		SMA50:          analysis50,
-		RSI:            smoothedRSI,
-		BollingerBands: bollingerbands,
-		RetracementLevels: calculateFibonacciRetracement(
-			dataHigh,
-			dataLow,
-		)}, nil
+		ChartPatterns:     DetectSwingChartPatterns(data, Config.ChartPatterns),
+		Zones:             findSupportResistanceZones(data, timeframe, Config.ChartPatterns),
+		VolumeProfile:     CalculateVolumeProfile(data, Config.VolumeProfile.Bins, Config.VolumeProfile.ValueArea),
+		Pivots:            calculatePivotPoints(prevCandle),
+		Candle:            currentCandle,
+		PrevCandle:        prevCandle,
+		SMA10:             analysis10,
+		SMA20:             analysis20,
+		SMA50:             analysis50,
+		RSI:               smoothedRSI,
+		BollingerBands:    bollingerbands,
+		RetracementLevels: fibonacci.Retracements,
+		Fibonacci:         fibonacci,
+	}, nil
 }
@@ -0,0 +1,175 @@
+package utils
+
+// VolumeProfileBin is the volume traded between Low and High, Price being the middle of the bin.
+type VolumeProfileBin struct {
+	Low, High, Price float64
+	Volume           float64
+}
+
+// VolumeProfile is the volume by price of a range of candles, with its point of control (the
+// busiest price), the value area around it and its high and low volume nodes.
+type VolumeProfile struct {
+	Low, High, BinSize float64
+	Bins               []VolumeProfileBin
+
+	POC                          float64
+	ValueAreaHigh, ValueAreaLow  float64
+	TotalVolume, ValueAreaVolume float64
+
+	HVN, LVN []float64
+}
+
+// CalculateVolumeProfile spreads the volume of each candle evenly over its high to low range and
+// buckets it into bins between the lowest low and the highest high. The value area grows from the
+// point of control towards the busier neighbouring bin until it holds valueArea of the volume.
+func CalculateVolumeProfile(data MarketData, bins int, valueArea float64) (profile VolumeProfile) {
+	if len(data.Close) == 0 || len(data.Volume) != len(data.Close) {
+		return
+	}
+
+	if bins < 2 {
+		bins = 24
+	}
+	if valueArea <= 0 || valueArea > 1 {
+		valueArea = 0.7
+	}
+
+	profile.Low, profile.High = data.Low[0], data.High[0]
+	for id := range data.Close {
+		if data.Low[id] < profile.Low {
+			profile.Low = data.Low[id]
+		}
+		if data.High[id] > profile.High {
+			profile.High = data.High[id]
+		}
+	}
+
+	if profile.High <= profile.Low {
+		return
+	}
+
+	profile.BinSize = (profile.High - profile.Low) / float64(bins)
+	profile.Bins = make([]VolumeProfileBin, bins)
+	for id := range profile.Bins {
+		profile.Bins[id].Low = profile.Low + float64(id)*profile.BinSize
+		profile.Bins[id].High = profile.Bins[id].Low + profile.BinSize
+		profile.Bins[id].Price = TruncateFloat(profile.Bins[id].Low+profile.BinSize/2, 8)
+	}
+
+	for id := range data.Close {
+		low, high, volume := data.Low[id], data.High[id], data.Volume[id]
+		if volume <= 0 {
+			continue
+		}
+		profile.TotalVolume += volume
+
+		// a candle without range trades all its volume at one price
+		if high <= low {
+			bin := int((low - profile.Low) / profile.BinSize)
+			if bin >= bins {
+				bin = bins - 1
+			}
+			profile.Bins[bin].Volume += volume
+			continue
+		}
+
+		for bin := range profile.Bins {
+			overlapLow, overlapHigh := profile.Bins[bin].Low, profile.Bins[bin].High
+			if low > overlapLow {
+				overlapLow = low
+			}
+			if high < overlapHigh {
+				overlapHigh = high
+			}
+			if overlapHigh > overlapLow {
+				profile.Bins[bin].Volume += volume * (overlapHigh - overlapLow) / (high - low)
+			}
+		}
+	}
+
+	if profile.TotalVolume == 0 {
+		return
+	}
+
+	poc := 0
+	for bin := range profile.Bins {
+		if profile.Bins[bin].Volume > profile.Bins[poc].Volume {
+			poc = bin
+		}
+	}
+	profile.POC = profile.Bins[poc].Price
+
+	lower, upper := poc, poc
+	profile.ValueAreaVolume = profile.Bins[poc].Volume
+	for profile.ValueAreaVolume < profile.TotalVolume*valueArea && (lower > 0 || upper < bins-1) {
+		var below, above float64 = -1, -1
+		if lower > 0 {
+			below = profile.Bins[lower-1].Volume
+		}
+		if upper < bins-1 {
+			above = profile.Bins[upper+1].Volume
+		}
+
+		if above >= below {
+			upper++
+			profile.ValueAreaVolume += above
+		} else {
+			lower--
+			profile.ValueAreaVolume += below
+		}
+	}
+	profile.ValueAreaLow = TruncateFloat(profile.Bins[lower].Low, 8)
+	profile.ValueAreaHigh = TruncateFloat(profile.Bins[upper].High, 8)
+
+	// nodes are the bins standing above or below both neighbours and the average bin
+	average := profile.TotalVolume / float64(bins)
+	for bin := 1; bin < bins-1; bin++ {
+		volume := profile.Bins[bin].Volume
+		previous, next := profile.Bins[bin-1].Volume, profile.Bins[bin+1].Volume
+		switch {
+		case volume > average && volume >= previous && volume >= next:
+			profile.HVN = append(profile.HVN, profile.Bins[bin].Price)
+		case volume < average && volume <= previous && volume <= next:
+			profile.LVN = append(profile.LVN, profile.Bins[bin].Price)
+		}
+	}
+
+	for bin := range profile.Bins {
+		profile.Bins[bin].Low = TruncateFloat(profile.Bins[bin].Low, 8)
+		profile.Bins[bin].High = TruncateFloat(profile.Bins[bin].High, 8)
+		profile.Bins[bin].Volume = TruncateFloat(profile.Bins[bin].Volume, 8)
+	}
+	return
+}
+
+// VolumeProfileZones turns the point of control, the value area edges and the high volume nodes of
+// a profile into zones one bin wide, support below price and resistance above it, with the share
+// of the volume traded in the bin as their strength.
+func VolumeProfileZones(profile VolumeProfile, price float64, timeframe string) (zones []SRZone) {
+	if len(profile.Bins) == 0 || profile.TotalVolume == 0 {
+		return
+	}
+
+	// the value area edges are bin boundaries, so look them up half a bin inside the area
+	halfBin := profile.BinSize / 2
+	levels := append([]float64{profile.POC, profile.ValueAreaLow + halfBin, profile.ValueAreaHigh - halfBin}, profile.HVN...)
+	seen := make(map[int]bool)
+	for _, level := range levels {
+		bin := int((level - profile.Low) / profile.BinSize)
+		if bin >= len(profile.Bins) {
+			bin = len(profile.Bins) - 1
+		}
+		if bin < 0 || seen[bin] {
+			continue
+		}
+		seen[bin] = true
+
+		zone := SRZone{Type: ZoneSupport, Price: profile.Bins[bin].Price, Low: profile.Bins[bin].Low, High: profile.Bins[bin].High,
+			Strength: TruncateFloat(profile.Bins[bin].Volume/profile.TotalVolume, 3), Timeframes: []string{timeframe}}
+		if zone.Price > price {
+			zone.Type = ZoneResistance
+		}
+		zones = append(zones, zone)
+	}
+	return
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"backpocket/utils"
+	"fmt"
+	"time"
+)
+
+// WsProtocolVersion is the version of the envelope and payloads sent on the websocket feeds, raised
+// whenever a change would break an existing client.
+const WsProtocolVersion = 1
+
+// wsEnvelopeType wraps every message of a websocket feed. Type names the payload, such as
+// "market", "orderbook" or "response", and Seq numbers the broadcasts of the feed so a client can
+// ask to resync from the last one it saw. Snapshots and responses are not broadcasts: their Seq is
+// 0 and AsOf is the last broadcast of the state they reflect, and a response carries the ID of the
+// request it answers.
+type wsEnvelopeType struct {
+	Type      string
+	Version   int
+	Seq       uint64
+	AsOf      uint64 `json:",omitempty"`
+	Timestamp time.Time
+	ID        string `json:",omitempty"`
+	Payload   interface{}
+}
+
+// wsResyncType is the result of a resync request, which either replayed the broadcasts after From
+// up to To or, when they are no longer held, sent the current state of the feed again.
+type wsResyncType struct {
+	From, To uint64
+	Replayed int
+	Snapshot bool
+}
+
+// envelope wraps a snapshot or response, unnumbered and as of the last broadcast of the hub.
+func (hub *wsHubType) envelope(msgType string, message interface{}) wsEnvelopeType {
+	hub.historyMutex.Lock()
+	asOf := hub.seq
+	hub.historyMutex.Unlock()
+
+	return wsEnvelopeType{Type: msgType, Version: WsProtocolVersion, AsOf: asOf, Timestamp: time.Now(), Payload: message}
+}
+
+// record numbers a broadcast and keeps it for resyncing. Only event feeds keep a history, a feed
+// that coalesces holding the latest state of each market which its snapshot sends instead.
+func (hub *wsHubType) record(msgType, key string, message interface{}, filter func(subscriptions *wsSubscriptionsType) (interface{}, bool)) wsEnvelopeType {
+	hub.historyMutex.Lock()
+	defer hub.historyMutex.Unlock()
+
+	hub.seq++
+	envelope := wsEnvelopeType{Type: msgType, Version: WsProtocolVersion, Seq: hub.seq, Timestamp: time.Now(), Payload: message}
+
+	if hub.Policy != WsPolicyCoalesce && utils.Config.Websocket.History > 0 {
+		hub.history = append(hub.history, wsHistoryType{Envelope: envelope, Key: key, Message: message, Filter: filter})
+		if len(hub.history) > utils.Config.Websocket.History {
+			hub.history = hub.history[len(hub.history)-utils.Config.Websocket.History:]
+		}
+	}
+	return envelope
+}
+
+// reply answers the request of id with the result of action, or with err when it failed.
+func (client *wsClientType) reply(id, action string, result interface{}, err error) {
+	response := wsResponseType{Action: action, Result: result}
+	if err != nil {
+		response.Error = err.Error()
+	}
+	client.respond(id, response)
+}
+
+// respond sends a response already holding its result or error to the request of id.
+func (client *wsClientType) respond(id string, response wsResponseType) {
+	envelope := client.hub.envelope("response", response)
+	envelope.ID = id
+	client.sendEnvelope("", envelope)
+}
+
+// resync sends the client what it missed after seq: the broadcasts of an event feed while its
+// history still holds them, otherwise the current state of the feed. A seq ahead of the hub, left
+// from before a restart, always gets the current state. Replayed broadcasts keep their sequence
+// numbers, so a client drops any it also receives live, while a snapshot has none to compare.
+func (client *wsClientType) resync(seq uint64, id string) {
+	hub := client.hub
+	subscriptions := client.Subscriptions()
+
+	hub.historyMutex.Lock()
+	current := hub.seq
+	replay := hub.Policy != WsPolicyCoalesce && seq <= current &&
+		(seq == current || (len(hub.history) > 0 && hub.history[0].Envelope.Seq <= seq+1))
+
+	var missed []wsHistoryType
+	if replay {
+		for _, history := range hub.history {
+			if history.Envelope.Seq > seq {
+				missed = append(missed, history)
+			}
+		}
+	}
+	hub.historyMutex.Unlock()
+
+	result := wsResyncType{From: seq, To: current}
+	switch {
+	case replay:
+		for _, history := range missed {
+			envelope := history.Envelope
+			if history.Filter != nil {
+				var ok bool
+				if envelope.Payload, ok = history.Filter(subscriptions); !ok {
+					continue
+				}
+			}
+			client.sendEnvelope(history.Key, envelope)
+			result.Replayed++
+		}
+
+	case client.snapshot != nil:
+		client.snapshot(client, subscriptions)
+		result.Snapshot = true
+
+	default:
+		client.reply(id, "resync", result, fmt.Errorf("Messages after %d are no longer available", seq))
+		return
+	}
+
+	client.reply(id, "resync", result, nil)
+}
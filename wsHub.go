@@ -0,0 +1,321 @@
+package main
+
+import (
+	"backpocket/utils"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// WsPolicyDrop drops the newest message for a client whose queue is full.
+	WsPolicyDrop = "drop"
+	// WsPolicyCoalesce replaces a queued message with a newer one of the same key, the latest
+	// state of a market being all a lagging client needs, and otherwise drops like WsPolicyDrop.
+	WsPolicyCoalesce = "coalesce"
+	// WsPolicyEvict disconnects a client whose queue is full, for feeds where a gap would leave it
+	// showing the wrong orders or balances until it reconnects and reloads.
+	WsPolicyEvict = "evict"
+)
+
+// wsHubType is the set of clients of one websocket feed. Broadcasting only queues messages, each
+// client having a writer goroutine of its own, so a slow browser never holds up the others or the
+// producers feeding the broadcast channels.
+type wsHubType struct {
+	Name   string
+	Policy string
+
+	mutex   sync.RWMutex
+	clients map[*wsClientType]bool
+
+	// seq numbers the broadcasts of the hub, history keeping the recent ones of an event feed
+	historyMutex sync.Mutex
+	seq          uint64
+	history      []wsHistoryType
+}
+
+type wsQueuedType struct {
+	Key      string
+	Envelope wsEnvelopeType
+}
+
+// wsHistoryType is a broadcast kept for resyncing, filtered again for the client asking.
+type wsHistoryType struct {
+	Envelope wsEnvelopeType
+	Key      string
+	Message  interface{}
+	Filter   func(subscriptions *wsSubscriptionsType) (interface{}, bool)
+}
+
+// wsClientType is a connection of a hub with its subscriptions and bounded send queue.
+type wsClientType struct {
+	hub  *wsHubType
+	conn *websocket.Conn
+
+	mutex         sync.Mutex
+	subscriptions *wsSubscriptionsType
+	// snapshot queues the current state of the feed the subscriptions match
+	snapshot  func(client *wsClientType, subscriptions *wsSubscriptionsType)
+	queue     []wsQueuedType
+	queueKeys map[string]int
+	// droppingSince is when the client started losing messages, cleared once it catches up
+	droppingSince time.Time
+	closed        bool
+
+	wake chan bool
+	done chan bool
+}
+
+var (
+	wsHubAssets             = newWsHub("assets", WsPolicyEvict)
+	wsHubNotifications      = newWsHub("notifications", WsPolicyEvict)
+	wsHubOrders             = newWsHub("orders", WsPolicyEvict)
+	wsHubOrderHistory       = newWsHub("orderhistory", WsPolicyEvict)
+	wsHubTrades             = newWsHub("trades", WsPolicyDrop)
+	wsHubMarkets            = newWsHub("markets", WsPolicyCoalesce)
+	wsHubOrderbooks         = newWsHub("orderbooks", WsPolicyCoalesce)
+	wsHubOrderbookAnalytics = newWsHub("orderbookanalytics", WsPolicyCoalesce)
+	wsHubAnalysis           = newWsHub("analysis", WsPolicyCoalesce)
+)
+
+func newWsHub(name, policy string) *wsHubType {
+	return &wsHubType{Name: name, Policy: policy, clients: make(map[*wsClientType]bool)}
+}
+
+// register adds an upgraded connection to the hub with the subscriptions of its query parameters and
+// starts its writer. Before any broadcast can reach it the client is sent what it missed after the
+// sequence number of its since parameter, or else the current state when the feed has a snapshot.
+func (hub *wsHubType) register(wsConn *websocket.Conn, httpReq *http.Request, snapshot func(client *wsClientType, subscriptions *wsSubscriptionsType)) *wsClientType {
+	client := &wsClientType{
+		hub:           hub,
+		conn:          wsConn,
+		subscriptions: wsSubscriptionsFromQuery(httpReq),
+		snapshot:      snapshot,
+		queueKeys:     make(map[string]int),
+		wake:          make(chan bool, 1),
+		done:          make(chan bool),
+	}
+
+	wsConn.SetPongHandler(func(string) error {
+		wsConn.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	hub.mutex.Lock()
+	if since, err := strconv.ParseUint(httpReq.URL.Query().Get("since"), 10, 64); err == nil {
+		client.resync(since, "")
+	} else if snapshot != nil {
+		snapshot(client, client.subscriptions)
+	}
+	hub.clients[client] = true
+	hub.mutex.Unlock()
+
+	go client.writer()
+	return client
+}
+
+// broadcast queues message as msgType for every client whose subscriptions pass filter, which may
+// also tailor the message to the client, or for every client when filter is nil. Clients the policy
+// evicts are disconnected once the hub is released.
+func (hub *wsHubType) broadcast(msgType, key string, message interface{}, filter func(subscriptions *wsSubscriptionsType) (interface{}, bool)) {
+	var evicted []*wsClientType
+
+	envelope := hub.record(msgType, key, message, filter)
+
+	hub.mutex.RLock()
+	for client := range hub.clients {
+		clientEnvelope := envelope
+		if filter != nil {
+			var ok bool
+			if clientEnvelope.Payload, ok = filter(client.Subscriptions()); !ok {
+				continue
+			}
+		}
+
+		if !client.publish(key, clientEnvelope) {
+			evicted = append(evicted, client)
+		}
+	}
+	hub.mutex.RUnlock()
+
+	for _, client := range evicted {
+		log.Printf("Websocket %s: evicting slow client %s \n", hub.Name, client.conn.RemoteAddr())
+		client.close()
+	}
+}
+
+// Subscriptions returns a copy of the subscriptions of the client.
+func (client *wsClientType) Subscriptions() *wsSubscriptionsType {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	subscriptions := &wsSubscriptionsType{Depth: client.subscriptions.Depth, Filtered: client.subscriptions.Filtered}
+	subscriptions.Filters = append(subscriptions.Filters, client.subscriptions.Filters...)
+	return subscriptions
+}
+
+// handle answers the subscribe, unsubscribe and resync requests every feed accepts, sending a
+// subscription the current state it matches, and reports whether msg was one of them.
+func (client *wsClientType) handle(msg wsSubscriptionMsgType) bool {
+	switch msg.Action {
+	case "subscribe", "unsubscribe":
+		requested := &wsSubscriptionsType{}
+		requested.handle(msg)
+
+		client.mutex.Lock()
+		client.subscriptions.handle(msg)
+		requested.Depth = client.subscriptions.Depth
+		client.mutex.Unlock()
+
+		if msg.Action == "subscribe" && requested.Filtered && client.snapshot != nil {
+			client.snapshot(client, requested)
+		}
+		client.reply(msg.ID, msg.Action, client.Subscriptions().Filters, nil)
+
+	case "resync":
+		client.resync(msg.Seq, msg.ID)
+
+	default:
+		return false
+	}
+	return true
+}
+
+func (client *wsClientType) setDepth(depth int) {
+	client.mutex.Lock()
+	client.subscriptions.Depth = depth
+	client.mutex.Unlock()
+}
+
+// send queues a snapshot message the client asked for as msgType, as of the last broadcast of the
+// hub, which is never dropped.
+func (client *wsClientType) send(msgType, key string, message interface{}) {
+	client.sendEnvelope(key, client.hub.envelope(msgType, message))
+}
+
+func (client *wsClientType) sendEnvelope(key string, envelope wsEnvelopeType) {
+	if client.hub.Policy != WsPolicyCoalesce {
+		key = ""
+	}
+
+	client.mutex.Lock()
+	client.enqueue(key, envelope)
+	client.mutex.Unlock()
+	client.signal()
+}
+
+// publish queues a broadcast under the policy of the hub, reporting false when the client is to be
+// evicted for having a full queue under WsPolicyEvict or for losing messages for longer than
+// websocket.slowconsumer.
+func (client *wsClientType) publish(key string, envelope wsEnvelopeType) bool {
+	client.mutex.Lock()
+	if client.closed {
+		client.mutex.Unlock()
+		return true
+	}
+
+	if client.hub.Policy != WsPolicyCoalesce {
+		key = ""
+	}
+
+	if _, queued := client.queueKeys[key]; (key == "" || !queued) && len(client.queue) >= utils.Config.Websocket.QueueSize {
+		if client.droppingSince.IsZero() {
+			client.droppingSince = time.Now()
+		}
+		slow := utils.Config.Websocket.SlowConsumer > 0 && time.Since(client.droppingSince) > utils.Config.Websocket.SlowConsumer
+		client.mutex.Unlock()
+		return client.hub.Policy != WsPolicyEvict && !slow
+	}
+
+	client.enqueue(key, envelope)
+	client.mutex.Unlock()
+	client.signal()
+	return true
+}
+
+// enqueue appends envelope, dropping the queued one of the same key so the queue stays in sequence
+// order, the caller holding client.mutex.
+func (client *wsClientType) enqueue(key string, envelope wsEnvelopeType) {
+	if index, queued := client.queueKeys[key]; key != "" && queued {
+		client.queue = append(client.queue[:index], client.queue[index+1:]...)
+		for queuedKey, queuedIndex := range client.queueKeys {
+			if queuedIndex > index {
+				client.queueKeys[queuedKey] = queuedIndex - 1
+			}
+		}
+	}
+
+	if key != "" {
+		client.queueKeys[key] = len(client.queue)
+	}
+	client.queue = append(client.queue, wsQueuedType{Key: key, Envelope: envelope})
+}
+
+func (client *wsClientType) signal() {
+	select {
+	case client.wake <- true:
+	default:
+	}
+}
+
+// writer is the only goroutine writing to the connection, sending the queued messages and the pings.
+func (client *wsClientType) writer() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-client.done:
+			return
+
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(utils.Config.Websocket.WriteTimeout))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				client.close()
+				return
+			}
+
+		case <-client.wake:
+			client.mutex.Lock()
+			queue := client.queue
+			client.queue = nil
+			client.queueKeys = make(map[string]int)
+			if len(queue) < utils.Config.Websocket.QueueSize {
+				client.droppingSince = time.Time{}
+			}
+			client.mutex.Unlock()
+
+			for _, queued := range queue {
+				client.conn.SetWriteDeadline(time.Now().Add(utils.Config.Websocket.WriteTimeout))
+				if err := client.conn.WriteJSON(queued.Envelope); err != nil {
+					client.close()
+					return
+				}
+			}
+		}
+	}
+}
+
+// close removes the client from its hub and closes the connection, ending both its writer and the
+// read loop of its handler.
+func (client *wsClientType) close() {
+	client.mutex.Lock()
+	if client.closed {
+		client.mutex.Unlock()
+		return
+	}
+	client.closed = true
+	client.queue = nil
+	close(client.done)
+	client.mutex.Unlock()
+
+	client.hub.mutex.Lock()
+	delete(client.hub.clients, client)
+	client.hub.mutex.Unlock()
+
+	client.conn.Close()
+}
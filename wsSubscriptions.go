@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// wsSubscriptionType is one filter a websocket client subscribed to, an empty field matching
+// anything. Event names what a feed publishes, such as an order status, a notification source,
+// an orderbook wall event or a trade bucket holding a "largetrade".
+type wsSubscriptionType struct {
+	Pair, Exchange, Interval, Event string
+}
+
+// wsSubscriptionsType holds the filters of a connection, which receives everything until it
+// subscribes to something, and for the orderbooks feed the levels a side it is sent. Filtered is
+// set by the first subscription and kept when the last filter is unsubscribed, so a chart moving
+// from one pair to the next receives nothing in between rather than every market.
+type wsSubscriptionsType struct {
+	Depth    int
+	Filtered bool
+	Filters  []wsSubscriptionType
+}
+
+// wsSubscriptionMsgType is the subscribe, unsubscribe and resync request every feed reads, the
+// filter fields being ignored by the other actions of a feed. ID is echoed in the response so a
+// client can match it to its request, and Seq is the last sequence number a resync has seen.
+type wsSubscriptionMsgType struct {
+	ID, Action, Pair, Exchange,
+	Interval, Event string
+	Seq           uint64
+	Subscriptions []wsSubscriptionType
+}
+
+func newWsSubscription(pair, exchange, interval, event string) wsSubscriptionType {
+	return wsSubscriptionType{
+		Pair:     strings.ToUpper(strings.TrimSpace(pair)),
+		Exchange: strings.ToLower(strings.TrimSpace(exchange)),
+		Interval: strings.TrimSpace(interval),
+		Event:    strings.ToLower(strings.TrimSpace(event)),
+	}
+}
+
+// wsSubscriptionsFromQuery starts a connection with the filter of its pair, exchange, interval and
+// event query parameters, so a chart can open a feed already narrowed to its market, and with the
+// orderbook depth of its depth parameter.
+func wsSubscriptionsFromQuery(httpReq *http.Request) *wsSubscriptionsType {
+	query := httpReq.URL.Query()
+	subscriptions := &wsSubscriptionsType{}
+	subscriptions.Depth, _ = strconv.Atoi(query.Get("depth"))
+
+	subscription := newWsSubscription(query.Get("pair"), query.Get("exchange"), query.Get("interval"), query.Get("event"))
+	if subscription != (wsSubscriptionType{}) {
+		subscriptions.Filtered = true
+		subscriptions.Filters = append(subscriptions.Filters, subscription)
+	}
+	return subscriptions
+}
+
+// handle applies a subscribe or unsubscribe request, reporting whether msg was one. Only
+// unsubscribing without any filter returns the connection to receiving everything.
+func (subscriptions *wsSubscriptionsType) handle(msg wsSubscriptionMsgType) bool {
+	requested := msg.Subscriptions
+	if len(requested) == 0 {
+		requested = []wsSubscriptionType{{Pair: msg.Pair, Exchange: msg.Exchange, Interval: msg.Interval, Event: msg.Event}}
+	}
+
+	switch msg.Action {
+	case "subscribe":
+		for _, request := range requested {
+			subscription := newWsSubscription(request.Pair, request.Exchange, request.Interval, request.Event)
+			if subscription == (wsSubscriptionType{}) || subscriptions.has(subscription) {
+				continue
+			}
+			subscriptions.Filtered = true
+			subscriptions.Filters = append(subscriptions.Filters, subscription)
+		}
+
+	case "unsubscribe":
+		for _, request := range requested {
+			subscription := newWsSubscription(request.Pair, request.Exchange, request.Interval, request.Event)
+			if subscription == (wsSubscriptionType{}) {
+				subscriptions.Filtered = false
+				subscriptions.Filters = nil
+				break
+			}
+
+			var filters []wsSubscriptionType
+			for _, filter := range subscriptions.Filters {
+				if filter != subscription {
+					filters = append(filters, filter)
+				}
+			}
+			subscriptions.Filters = filters
+		}
+
+	default:
+		return false
+	}
+	return true
+}
+
+// all reports whether the connection receives everything, having never subscribed or reset.
+func (subscriptions *wsSubscriptionsType) all() bool {
+	return subscriptions == nil || !subscriptions.Filtered
+}
+
+func (subscriptions *wsSubscriptionsType) has(subscription wsSubscriptionType) bool {
+	for _, filter := range subscriptions.Filters {
+		if filter == subscription {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether an item of pair, exchange and interval carrying any of events passes a
+// filter of the connection. An item without a pair, exchange or interval, such as a notification
+// that is not about a market, passes a filter on it.
+func (subscriptions *wsSubscriptionsType) matches(pair, exchange, interval string, events ...string) bool {
+	if subscriptions.all() {
+		return true
+	}
+
+	for _, filter := range subscriptions.Filters {
+		if filter.match(pair, exchange, interval, events...) {
+			return true
+		}
+	}
+	return false
+}
+
+func (filter wsSubscriptionType) match(pair, exchange, interval string, events ...string) bool {
+	if filter.Pair != "" && pair != "" && !strings.EqualFold(filter.Pair, pair) {
+		return false
+	}
+
+	if filter.Exchange != "" && exchange != "" && !strings.EqualFold(filter.Exchange, exchange) {
+		return false
+	}
+
+	if filter.Interval != "" && interval != "" && filter.Interval != interval {
+		return false
+	}
+
+	if filter.Event == "" {
+		return true
+	}
+
+	for _, event := range events {
+		if strings.EqualFold(filter.Event, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// intervals returns the intervals a connection wants of the analysis of a market, all of them when
+// a matching filter names none, and whether it wants the market at all.
+func (subscriptions *wsSubscriptionsType) intervals(pair, exchange string) (intervals map[string]bool, subscribed bool) {
+	if subscriptions.all() {
+		return nil, true
+	}
+
+	for _, filter := range subscriptions.Filters {
+		if filter.Event != "" || !filter.match(pair, exchange, "") {
+			continue
+		}
+
+		if filter.Interval == "" {
+			return nil, true
+		}
+
+		if intervals == nil {
+			intervals = make(map[string]bool)
+		}
+		intervals[filter.Interval] = true
+		subscribed = true
+	}
+	return
+}